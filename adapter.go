@@ -0,0 +1,59 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MapAdapter registers fn, a func taking exactly one argument and returning
+// exactly one value, e.g. func(*zap.Logger) *zap.SugaredLogger, as the
+// adapter producing its result type from an existing binding of its
+// argument type. Unlike a provider, an adapter is called again on every
+// resolution of its result type that finds nothing mapped, with the value
+// its argument resolves to at that time, and its result is not mapped.
+// It panics if fn is not a func of that shape.
+func (i *injector) MapAdapter(fn interface{}) TypeMapper {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 1 || t.IsVariadic() {
+		panic(fmt.Sprintf("inject adapter must be a func taking one argument and returning one value, got %v", t))
+	}
+
+	i.mu.Lock()
+	i.adapters[t.Out(0)] = reflect.ValueOf(fn)
+	i.mu.Unlock()
+	return i
+}
+
+// adapterFor returns the adapter registered for t by i or the nearest of its
+// parents, or the zero Value.
+func (i *injector) adapterFor(t reflect.Type) reflect.Value {
+	for inj, ok := i, true; ok; inj, ok = inj.parent().(*injector) {
+		inj.mu.RLock()
+		fn := inj.adapters[t]
+		inj.mu.RUnlock()
+		if fn.IsValid() {
+			return fn
+		}
+	}
+	return reflect.Value{}
+}
+
+// adapt produces t with its adapter, if any, resolving the argument of the
+// adapter for the resolution constructing the types on path. It returns the
+// zero Value if there is no adapter for t or its argument cannot be
+// resolved.
+func (i *injector) adapt(t reflect.Type, path *construction) (reflect.Value, error) {
+	fn := i.adapterFor(t)
+	if !fn.IsValid() {
+		return reflect.Value{}, nil
+	}
+	if path.has(t) {
+		return reflect.Value{}, fmt.Errorf("%w: %v", ErrCircularDependency, &construction{t, path})
+	}
+
+	arg, err := i.resolveArgOn(fn.Type().In(0), &construction{t, path})
+	if err != nil || !arg.IsValid() {
+		return reflect.Value{}, err
+	}
+	return fn.Call([]reflect.Value{arg})[0], nil
+}
@@ -0,0 +1,59 @@
+package inject_test
+
+import (
+	"errors"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"testing"
+)
+
+type BaseLogger struct {
+	Prefix string
+}
+
+type SugaredLogger struct {
+	Base *BaseLogger
+}
+
+func Test_InjectorMapAdapter(t *testing.T) {
+	injector := inject.New()
+	calls := 0
+	injector.MapAdapter(func(l *BaseLogger) *SugaredLogger {
+		calls++
+		return &SugaredLogger{l}
+	})
+
+	// without the adapted binding there is nothing to adapt
+	_, err := injector.Invoke(func(s *SugaredLogger) {})
+	refute(t, err, nil)
+	expect(t, calls, 0)
+
+	injector.Map(&BaseLogger{"app"})
+	_, err = injector.Invoke(func(s *SugaredLogger) {
+		expect(t, s.Base.Prefix, "app")
+	})
+	expect(t, err, nil)
+
+	// adapted on demand, from the current binding
+	injector.Map(&BaseLogger{"reloaded"})
+	child := inject.New()
+	child.SetParent(injector)
+	_, err = child.Invoke(func(s *SugaredLogger) {
+		expect(t, s.Base.Prefix, "reloaded")
+	})
+	expect(t, err, nil)
+	expect(t, calls, 2)
+
+	msg := panicMessage(func() { injector.MapAdapter(func(a, b *BaseLogger) *SugaredLogger { return nil }) })
+	expect(t, msg, "inject adapter must be a func taking one argument and returning one value, got func(*inject_test.BaseLogger, *inject_test.BaseLogger) *inject_test.SugaredLogger")
+}
+
+func Test_InjectorMapAdapterCycle(t *testing.T) {
+	injector := inject.New()
+	injector.MapAdapter(func(s *SugaredLogger) *BaseLogger { return s.Base })
+	injector.MapAdapter(func(l *BaseLogger) *SugaredLogger { return &SugaredLogger{l} })
+
+	_, err := injector.Invoke(func(s *SugaredLogger) {})
+	expect(t, errors.Is(err, inject.ErrCircularDependency), true)
+	expect(t, injector.Has(reflect.TypeOf(&SugaredLogger{})), false)
+}
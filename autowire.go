@@ -0,0 +1,89 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// wiredConstructor is a constructor registered with AutoWire returning
+// several values. It is invoked at most once successfully, whichever of its
+// return types is requested first, and maps all of them.
+type wiredConstructor struct {
+	mu   sync.Mutex
+	fn   reflect.Value
+	out  []reflect.Value
+	done bool
+}
+
+// AutoWire registers every constructor as the provider of each of its return
+// values, except a trailing error, like MapProvider does for the first one.
+// Registering constructors alone builds a lazy dependency graph: requesting
+// any of their return types constructs it, and the values it needs from the
+// other constructors, on first use. A constructor returning several values
+// is invoked once and maps all of them. Missing dependencies fail with an
+// error naming the chain of types being constructed, and cycles fail with
+// ErrCircularDependency. It panics if a constructor is not a func returning
+// at least one value besides the error.
+func (i *injector) AutoWire(constructors ...interface{}) TypeMapper {
+	for _, c := range constructors {
+		t := reflect.TypeOf(c)
+		if t == nil || t.Kind() != reflect.Func {
+			panic(fmt.Sprintf("inject constructor must be a func, got %v", t))
+		}
+		outs := t.NumOut()
+		if outs > 0 && t.Out(outs-1) == errorType {
+			outs--
+		}
+		if outs == 0 {
+			panic(fmt.Sprintf("inject constructor must return a value, got %v", t))
+		}
+
+		if outs == 1 {
+			i.MapProvider(c)
+			continue
+		}
+
+		w := &wiredConstructor{fn: reflect.ValueOf(c)}
+		for n := 0; n < outs; n++ {
+			n := n
+			i.addProvider(&provider{fn: w.fn, typ: t.Out(n), call: func(i *injector, path *construction) (reflect.Value, error) {
+				out, err := w.construct(i, path)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				return out[n], nil
+			}}, nil)
+		}
+	}
+	return i
+}
+
+// construct invokes the constructor once, mapping all its return values but
+// the one requested, which its provider maps.
+func (w *wiredConstructor) construct(i *injector, path *construction) ([]reflect.Value, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done {
+		return w.out, nil
+	}
+
+	out, err := i.invoke(w.fn.Interface(), i.resolverOn(path))
+	if err != nil {
+		return nil, err
+	}
+	if last := out[len(out)-1]; last.Type() == errorType {
+		if err, _ := last.Interface().(error); err != nil {
+			return nil, err
+		}
+		out = out[:len(out)-1]
+	}
+
+	for n, v := range out {
+		if t := w.fn.Type().Out(n); t != path.typ {
+			i.Set(t, v)
+		}
+	}
+	w.out, w.done = out, true
+	return out, nil
+}
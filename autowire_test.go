@@ -0,0 +1,66 @@
+package inject_test
+
+import (
+	"errors"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Handlers struct {
+	Repo *UserRepo
+}
+
+type Metrics struct{}
+
+func Test_InjectorAutoWire(t *testing.T) {
+	constructed := map[string]int{}
+	injector := inject.New()
+	injector.AutoWire(
+		func(repo *UserRepo) *Handlers {
+			constructed["handlers"]++
+			return &Handlers{repo}
+		},
+		func(db *DB) (*UserRepo, error) {
+			constructed["repo"]++
+			return &UserRepo{db}, nil
+		},
+		func(c *Config) (*DB, *Metrics) {
+			constructed["db"]++
+			return &DB{c.DSN}, &Metrics{}
+		},
+	)
+	injector.Map(&Config{"postgres://"})
+
+	h := injector.Get(reflect.TypeOf(&Handlers{}))
+	expect(t, h.IsValid(), true)
+	expect(t, h.Interface().(*Handlers).Repo.DB.DSN, "postgres://")
+
+	// constructors returning several values map all of them at once
+	expect(t, injector.Get(reflect.TypeOf(&Metrics{})).IsValid(), true)
+	injector.Get(reflect.TypeOf(&DB{}))
+	expect(t, constructed["handlers"], 1)
+	expect(t, constructed["repo"], 1)
+	expect(t, constructed["db"], 1)
+}
+
+func Test_InjectorAutoWireErrors(t *testing.T) {
+	injector := inject.New()
+	injector.AutoWire(
+		func(repo *UserRepo) *Handlers { return &Handlers{repo} },
+		func(db *DB) *UserRepo { return &UserRepo{db} },
+		func(c *Config) *DB { return &DB{c.DSN} },
+	)
+
+	_, err := injector.Invoke(func(h *Handlers) {})
+	refute(t, err, nil)
+	expect(t, strings.HasSuffix(err.Error(), "Value not found for type *inject_test.Config, needed by *inject_test.Handlers -> *inject_test.UserRepo -> *inject_test.DB"), true)
+
+	injector.AutoWire(func(h *Handlers) *Config { return &Config{} })
+	_, err = injector.Invoke(func(h *Handlers) {})
+	expect(t, errors.Is(err, inject.ErrCircularDependency), true)
+
+	expect(t, panicMessage(func() { injector.AutoWire(func() error { return nil }) }), "inject constructor must return a value, got func() error")
+	expect(t, panicMessage(func() { injector.AutoWire(&Config{}) }), "inject constructor must be a func, got *inject_test.Config")
+}
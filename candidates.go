@@ -0,0 +1,77 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// candidateTypes returns the candidate type names of a `types=` tag, e.g.
+// `inject:"types=*RedisCache,*MemCache,optional"`, and the remaining tag
+// options. It returns nil if the tag does not list candidates.
+func candidateTypes(name string, opts []string) ([]string, []string) {
+	if !strings.HasPrefix(name, "types=") {
+		return nil, opts
+	}
+
+	candidates := []string{strings.TrimPrefix(name, "types=")}
+	var rest []string
+	for _, o := range opts {
+		switch o {
+		case "named", "optional":
+			rest = append(rest, o)
+		default:
+			candidates = append(candidates, o)
+		}
+	}
+	return candidates, rest
+}
+
+// typeMatches reports whether t is named name, either in full, e.g.
+// "*cache.RedisCache", or without its package, e.g. "*RedisCache".
+func typeMatches(t reflect.Type, name string) bool {
+	if t.String() == name {
+		return true
+	}
+	stars := ""
+	for t.Kind() == reflect.Ptr && t.Name() == "" {
+		stars += "*"
+		t = t.Elem()
+	}
+	return t.Name() != "" && stars+t.Name() == name
+}
+
+// knownTypes returns the types mapped, or having a provider, in i and its
+// parents, nearest injector first.
+func (i *injector) knownTypes() []reflect.Type {
+	var types []reflect.Type
+	for inj, ok := i, true; ok; inj, ok = inj.parent().(*injector) {
+		inj.mu.RLock()
+		types = append(types, inj.order...)
+		types = append(types, inj.provided...)
+		inj.mu.RUnlock()
+	}
+	return types
+}
+
+// getCandidate resolves the first of the candidate types, in priority
+// order, that can be resolved. A candidate is matched by name against the
+// types mapped in i and its parents and must be assignable to t. It returns
+// the zero Value if no candidate can be resolved.
+func (i *injector) getCandidate(t reflect.Type, candidates []string) (reflect.Value, error) {
+	known := i.knownTypes()
+	for _, name := range candidates {
+		for _, k := range known {
+			if !typeMatches(k, name) {
+				continue
+			}
+			if !k.AssignableTo(t) {
+				return reflect.Value{}, fmt.Errorf("Candidate type %v is not assignable to %v", k, t)
+			}
+			if v, err := i.resolveArg(k); err == nil && v.IsValid() {
+				return v, nil
+			}
+		}
+	}
+	return reflect.Value{}, nil
+}
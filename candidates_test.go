@@ -0,0 +1,83 @@
+package inject_test
+
+import (
+	"github.com/codegangsta/inject"
+	"testing"
+)
+
+type CacheStore interface {
+	Backend() string
+}
+
+type RedisCache struct{}
+
+func (*RedisCache) Backend() string { return "redis" }
+
+type MemCache struct{}
+
+func (*MemCache) Backend() string { return "memory" }
+
+type CachedService struct {
+	Cache CacheStore `inject:"types=*RedisCache,*MemCache"`
+}
+
+type OptionalCachedService struct {
+	Cache CacheStore `inject:"types=*RedisCache,*inject_test.MemCache,optional"`
+}
+
+func Test_InjectorApplyCandidateTypes(t *testing.T) {
+	parent := inject.New()
+	parent.Map(&MemCache{})
+
+	injector := inject.New()
+	injector.SetParent(parent)
+
+	// the preferred cache is missing
+	s := CachedService{}
+	expect(t, injector.Apply(&s), nil)
+	expect(t, s.Cache.Backend(), "memory")
+
+	injector.MapProvider(func() *RedisCache { return &RedisCache{} })
+	expect(t, injector.Apply(&s), nil)
+	expect(t, s.Cache.Backend(), "redis")
+
+	err := inject.New().Apply(&CachedService{})
+	refute(t, err, nil)
+	expect(t, err.Error(), "Field Cache: none of the types *RedisCache, *MemCache found")
+
+	o := OptionalCachedService{}
+	expect(t, inject.New().Apply(&o), nil)
+	expect(t, o.Cache, nil)
+	expect(t, parent.Apply(&o), nil)
+	expect(t, o.Cache.Backend(), "memory")
+}
+
+type MisdeclaredService struct {
+	Cache CacheStore `inject:"types=*Config"`
+}
+
+func Test_InjectorApplyCandidateNotAssignable(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&Config{})
+
+	err := injector.Apply(&MisdeclaredService{})
+	refute(t, err, nil)
+	expect(t, err.Error(), "Field Cache: Candidate type *inject_test.Config is not assignable to inject_test.CacheStore")
+}
+
+type OptionalDeps struct {
+	Name  string `inject:",optional"`
+	Count int    `inject:"t"`
+	DB    *DB    `inject:"optional"`
+}
+
+func Test_InjectorApplyOptional(t *testing.T) {
+	injector := inject.New()
+	injector.Map(3)
+
+	d := OptionalDeps{Name: "default"}
+	expect(t, injector.Apply(&d), nil)
+	expect(t, d.Name, "default")
+	expect(t, d.Count, 3)
+	expect(t, d.DB == nil, true)
+}
@@ -0,0 +1,74 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MapCommandHandlers registers the methods of service whose name starts
+// with Handle and that take at least one parameter as command handlers:
+// the type of the first parameter is the command a method handles, see
+// InvokeHandler. It panics if service has no such method or one of them
+// handles a command already handled by another method registered with this
+// injector.
+func (i *injector) MapCommandHandlers(service interface{}) TypeMapper {
+	v := reflect.ValueOf(service)
+	registered := 0
+	for n := 0; n < v.NumMethod(); n++ {
+		m := v.Method(n)
+		if !strings.HasPrefix(v.Type().Method(n).Name, "Handle") || m.Type().NumIn() == 0 {
+			continue
+		}
+
+		cmd := m.Type().In(0)
+		i.mu.Lock()
+		_, dup := i.commands[cmd]
+		if !dup {
+			i.commands[cmd] = m
+		}
+		i.mu.Unlock()
+		if dup {
+			panic(fmt.Sprintf("inject: command %v of %v.%s is already handled", cmd, v.Type(), v.Type().Method(n).Name))
+		}
+		registered++
+	}
+
+	if registered == 0 {
+		panic(fmt.Sprintf("inject: %v has no Handle method taking a command", v.Type()))
+	}
+	return i
+}
+
+// InvokeHandler calls the command handler registered with MapCommandHandlers
+// for the type of cmd, in this injector or the nearest of its parents, with
+// cmd as its first argument and the others injected like Invoke. It returns
+// the values the handler returned, or an error if no handler is registered
+// for the type of cmd or an argument cannot be resolved.
+func (i *injector) InvokeHandler(cmd interface{}) ([]reflect.Value, error) {
+	t := reflect.TypeOf(cmd)
+	var handler reflect.Value
+	for inj, ok := i, true; ok && !handler.IsValid(); inj, ok = inj.parent().(*injector) {
+		inj.mu.RLock()
+		handler = inj.commands[t]
+		inj.mu.RUnlock()
+	}
+	if !handler.IsValid() {
+		return nil, fmt.Errorf("No handler registered for command type %v", t)
+	}
+
+	ht := handler.Type()
+	in := make([]reflect.Value, ht.NumIn())
+	in[0] = reflect.ValueOf(cmd)
+	for n := 1; n < len(in); n++ {
+		val, err := i.resolveArg(ht.In(n))
+		if err != nil {
+			return nil, err
+		}
+		if !val.IsValid() {
+			return nil, fmt.Errorf("Value not found for type %v", ht.In(n))
+		}
+		in[n] = val
+	}
+	return handler.Call(in), nil
+}
@@ -0,0 +1,56 @@
+package inject_test
+
+import (
+	"github.com/codegangsta/inject"
+	"strings"
+	"testing"
+)
+
+type CreateUser struct{ Name string }
+type DeleteUser struct{ ID int }
+type RenameUser struct{ ID int }
+
+type AccountService struct {
+	log []string
+}
+
+func (s *AccountService) HandleCreate(cmd CreateUser, db *DB) string {
+	s.log = append(s.log, "create "+cmd.Name+" in "+db.DSN)
+	return cmd.Name
+}
+
+func (s *AccountService) HandleDelete(cmd DeleteUser) {
+	s.log = append(s.log, "delete")
+}
+
+// not a handler
+func (s *AccountService) Count(cmd CreateUser) int { return len(s.log) }
+
+func Test_InjectorInvokeHandler(t *testing.T) {
+	parent := inject.New()
+	service := &AccountService{}
+	parent.MapCommandHandlers(service)
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.Map(&DB{"postgres://"})
+
+	out, err := injector.InvokeHandler(CreateUser{"bob"})
+	expect(t, err, nil)
+	expect(t, out[0].Interface(), "bob")
+
+	_, err = injector.InvokeHandler(DeleteUser{1})
+	expect(t, err, nil)
+	expect(t, strings.Join(service.log, ","), "create bob in postgres://,delete")
+
+	_, err = injector.InvokeHandler(RenameUser{1})
+	expect(t, err.Error(), "No handler registered for command type inject_test.RenameUser")
+
+	// the parent cannot resolve the *DB of its handler
+	_, err = parent.InvokeHandler(CreateUser{"bob"})
+	expect(t, err.Error(), "Value not found for type *inject_test.DB")
+
+	msg := panicMessage(func() { parent.MapCommandHandlers(&AccountService{}) })
+	expect(t, msg, "inject: command inject_test.CreateUser of *inject_test.AccountService.HandleCreate is already handled")
+	msg = panicMessage(func() { parent.MapCommandHandlers(&DB{}) })
+	expect(t, msg, "inject: *inject_test.DB has no Handle method taking a command")
+}
@@ -0,0 +1,74 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var configType = reflect.TypeOf(map[string]interface{}(nil))
+
+// configPath returns the dotted path of a `config:` tag, e.g.
+// `inject:"config:server.port"`, and whether the tag is one.
+func configPath(name string) (string, bool) {
+	if !strings.HasPrefix(name, "config:") {
+		return "", false
+	}
+	return strings.TrimPrefix(name, "config:"), true
+}
+
+// getConfig returns the value at the dotted path in the map[string]interface{}
+// mapped in i, e.g. decoded JSON configuration, converted to t. Every
+// element of the path but the last must be a map with string keys. It
+// returns the zero Value if no config map is mapped or the path is missing.
+func (i *injector) getConfig(t reflect.Type, path string) (reflect.Value, error) {
+	v, err := i.lookup(configType, nil)
+	if err != nil || !v.IsValid() {
+		return reflect.Value{}, err
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, nil
+		}
+		if v = v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())); !v.IsValid() {
+			return reflect.Value{}, nil
+		}
+	}
+	return convertConfig(v, t, path)
+}
+
+// convertConfig converts the config value v found at path to t. Numbers
+// convert between numeric kinds, as long as no fraction is lost, since JSON
+// decodes every number to a float64.
+func convertConfig(v reflect.Value, t reflect.Type, path string) (reflect.Value, error) {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+
+	switch {
+	case isNumber(v.Kind()) && isNumber(t.Kind()):
+		c := v.Convert(t)
+		if isFloat(v.Kind()) && !isFloat(t.Kind()) && c.Convert(v.Type()).Float() != v.Float() {
+			break
+		}
+		return c, nil
+	case v.Kind() == t.Kind() && v.Type().ConvertibleTo(t):
+		return v.Convert(t), nil
+	}
+	return reflect.Value{}, fmt.Errorf("Config path %q holds %v, which cannot be converted to %v", path, v.Type(), t)
+}
+
+func isNumber(k reflect.Kind) bool {
+	return (k >= reflect.Int && k <= reflect.Uint64) || isFloat(k)
+}
+
+func isFloat(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
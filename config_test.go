@@ -0,0 +1,55 @@
+package inject_test
+
+import (
+	"encoding/json"
+	"github.com/codegangsta/inject"
+	"testing"
+)
+
+type Hostname string
+
+type ServerConfig struct {
+	Host    Hostname               `inject:"config:server.host"`
+	Port    int                    `inject:"config:server.port"`
+	Ratio   float32                `inject:"config:server.ratio"`
+	Debug   bool                   `inject:"config:debug"`
+	Limits  map[string]interface{} `inject:"config:server.limits"`
+	Timeout int                    `inject:"config:server.timeout,optional"`
+}
+
+func Test_InjectorApplyConfig(t *testing.T) {
+	var config map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"debug": true,
+		"server": {"host": "localhost", "port": 8080, "ratio": 0.5, "limits": {"conns": 10}}
+	}`), &config)
+	expect(t, err, nil)
+
+	injector := inject.New()
+	injector.Map(config)
+
+	s := ServerConfig{Timeout: 30}
+	expect(t, injector.Apply(&s), nil)
+	expect(t, s.Host, Hostname("localhost"))
+	expect(t, s.Port, 8080)
+	expect(t, s.Ratio, float32(0.5))
+	expect(t, s.Debug, true)
+	expect(t, s.Limits["conns"], float64(10))
+	expect(t, s.Timeout, 30)
+
+	missing := struct {
+		Name string `inject:"config:server.name"`
+	}{}
+	err = injector.Apply(&missing)
+	expect(t, err.Error(), `Field Name: config path "server.name" not found`)
+
+	lossy := struct {
+		Port int `inject:"config:server.ratio"`
+	}{}
+	err = injector.Apply(&lossy)
+	expect(t, err.Error(), `Field Port: Config path "server.ratio" holds float64, which cannot be converted to int`)
+
+	// without a config map no path is found
+	err = inject.New().Apply(&missing)
+	expect(t, err.Error(), `Field Name: config path "server.name" not found`)
+}
@@ -0,0 +1,62 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Redactor is implemented by values that must not be revealed by Dump, e.g.
+// credentials, to tell how they are shown instead.
+type Redactor interface {
+	Redact() string
+}
+
+// WithDumpValues makes Dump print the values mapped, formatted with %v,
+// instead of only their types. It is meant for debugging injectors holding
+// nothing sensitive: values implementing Redactor are still redacted.
+func WithDumpValues() Option {
+	return func(i *injector) {
+		i.dumpValues = true
+	}
+}
+
+// Dump returns the values mapped in the injector and its parents, one line
+// per type in the form "type: value", in registration order and starting
+// with the injector's own values. Types mapped again by a nearer injector
+// are listed once. A value implementing Redactor is shown as what its
+// Redact method returns; other values are shown as their type only, unless
+// the injector was created WithDumpValues.
+func (i *injector) Dump() string {
+	var b strings.Builder
+	seen := make(map[reflect.Type]bool)
+	for inj, ok := i, true; ok; inj, ok = inj.parent().(*injector) {
+		inj.mu.RLock()
+		for _, t := range inj.order {
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			fmt.Fprintf(&b, "%v: %s\n", t, i.dumpValue(inj.values[t]))
+		}
+		inj.mu.RUnlock()
+	}
+	return b.String()
+}
+
+// dumpValue returns how Dump shows v.
+func (i *injector) dumpValue(v reflect.Value) string {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "<nil>"
+	}
+	if r, ok := v.Interface().(Redactor); ok {
+		return r.Redact()
+	}
+	if i.dumpValues {
+		return fmt.Sprintf("%v", v.Interface())
+	}
+	return v.Type().String()
+}
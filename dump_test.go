@@ -0,0 +1,36 @@
+package inject_test
+
+import (
+	"fmt"
+	"github.com/codegangsta/inject"
+	"testing"
+)
+
+type APIKey string
+
+func (k APIKey) Redact() string {
+	return "APIKey(" + string(k[:3]) + "...)"
+}
+
+func Test_InjectorDump(t *testing.T) {
+	parent := inject.New()
+	parent.Map(APIKey("sk-1234567890"))
+	parent.Map("shadowed")
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.Map("secret-password")
+	injector.MapTo(&Greeter{"Jeremy"}, (*fmt.Stringer)(nil))
+
+	expect(t, injector.Dump(), "string: string\n"+
+		"fmt.Stringer: *inject_test.Greeter\n"+
+		"inject_test.APIKey: APIKey(sk-...)\n")
+}
+
+func Test_InjectorDumpValues(t *testing.T) {
+	injector := inject.New(inject.WithDumpValues())
+	injector.Map(APIKey("sk-1234567890"))
+	injector.Map(8080)
+
+	expect(t, injector.Dump(), "inject_test.APIKey: APIKey(sk-...)\n"+
+		"int: 8080\n")
+}
@@ -0,0 +1,325 @@
+package inject
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// DefaultRequestTimeout is how long Request waits for a reply unless the
+// injector was created with WithRequestTimeout.
+const DefaultRequestTimeout = 5 * time.Second
+
+var (
+	// ErrNoReply is returned by Request when no handler replied.
+	ErrNoReply = errors.New("no handler replied")
+	// ErrRequestTimeout is returned by Request when the reply did not arrive
+	// in time.
+	ErrRequestTimeout = errors.New("request timed out")
+)
+
+// WithRequestTimeout sets how long Request waits for a reply.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(i *injector) {
+		i.requestTimeout = d
+	}
+}
+
+// ErrHandlersFrozen is the error On and OnFrom panic with, wrapped, when
+// they are called after Start on an injector created WithFrozenHandlers.
+var ErrHandlersFrozen = errors.New("handlers are frozen")
+
+// WithFrozenHandlers makes the handlers registered with On and OnFrom
+// final once the event loop is started: registering handlers afterwards
+// panics with ErrHandlersFrozen. Since the handler set cannot change
+// anymore, dispatching an event then looks its handlers up without taking
+// the injector's lock, which keeps a busy event loop from contending with
+// resolutions. By default handlers can be registered at any time. Stopping
+// the event loop does not unfreeze the handlers.
+func WithFrozenHandlers() Option {
+	return func(i *injector) {
+		i.freeze = true
+	}
+}
+
+// EventLoopExpired is the key of the event dispatched by an event loop
+// started with StartCtx or StartFor when it stops because its context is
+// done. Its data is the error of the context.
+const EventLoopExpired = "inject.loop.expired"
+
+// StartCtx starts the event loop like Start, and stops it once ctx is done.
+// The event being dispatched when ctx is done is completed first; events
+// fired afterwards are not dispatched anymore, like after Stop. Before the
+// loop stops it dispatches an EventLoopExpired event, in the loop's
+// goroutine, if this injector or one of its parents handles it.
+func (i *injector) StartCtx(ctx context.Context) {
+	i.startLoop(ctx)
+}
+
+// startLoop runs the start hooks, see StartHooks, unless they already ran,
+// starts the event loop and returns a channel closed when it has stopped.
+// If a start hook fails the loop is not started and the channel is closed.
+func (i *injector) startLoop(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	if err := i.StartHooks(ctx); err != nil && !errors.Is(err, errHooksStarted) {
+		i.mu.Lock()
+		i.loopDone = done
+		i.mu.Unlock()
+		close(done)
+		i.hooksFailed(err)
+		return done
+	}
+	i.mu.Lock()
+	i.loopDone = done
+	if i.freeze {
+		i.frozen.Store(true)
+	}
+	i.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case e := <-i.events:
+				i.run(e)
+			case <-i.stopped:
+				return
+			case <-ctx.Done():
+				if i.hasHandlers(EventLoopExpired) {
+					i.run(Event{Src: i, Type: EventLoopExpired, Data: ctx.Err()})
+				}
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// StartFor starts the event loop like StartCtx with a context timing out
+// after d, for workers and tests with a bounded lifetime.
+func (i *injector) StartFor(d time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	done := i.startLoop(ctx)
+	go func() {
+		<-done
+		cancel()
+	}()
+}
+
+// Context returns the context e was fired with by FireCtx, or
+// context.Background for events fired otherwise.
+func (e Event) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// FireCtx fires an event for key carrying data, like Fire, with ctx as its
+// context. Handlers receive ctx when they take a context.Context argument,
+// e.g. func(context.Context, Event), and should stop their work once it is
+// done; handlers not yet run when it is done are skipped. FireCtx gives up
+// sending the event if ctx is done before the event loop accepts it.
+func (i *injector) FireCtx(ctx context.Context, key string, data interface{}) {
+	i.checkPayload(key, data)
+	if !i.hasHandlers(key) {
+		return
+	}
+
+	e := Event{
+		Src:  i,
+		Type: key,
+		Data: data,
+		ctx:  ctx,
+	}
+	select {
+	case i.events <- e:
+	case <-ctx.Done():
+	}
+}
+
+// FireSync dispatches an event for key carrying data and ctx like FireCtx,
+// but runs the handlers in the calling goroutine and returns once they are
+// done; the event loop does not need to be started. The event goes to the
+// nearest of this injector and its parents having handlers for key. A
+// parent not created by New receives it on its Events channel instead.
+func (i *injector) FireSync(ctx context.Context, key string, data interface{}) {
+	i.checkPayload(key, data)
+	i.fireSync(ctx, key, data)
+}
+
+// FireSyncCtx runs the handlers of an event for key like FireSync, but
+// returns an error wrapping the error of ctx, e.g. context.DeadlineExceeded,
+// as soon as ctx is done, instead of waiting for a slow handler. The
+// handlers then keep running in the background: the one running receives
+// ctx and should return once it is done, and the following ones are
+// skipped. Since the handlers run in their own goroutine, a handler
+// panicking is not recovered by the caller of FireSyncCtx. If ctx is done
+// already no handler runs.
+func (i *injector) FireSyncCtx(ctx context.Context, key string, data interface{}) error {
+	i.checkPayload(key, data)
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("event %q: %w", key, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		i.fireSync(ctx, key, data)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("event %q: %w", key, ctx.Err())
+	}
+}
+
+// fireSync dispatches an event for key in the calling goroutine, see
+// FireSync.
+func (i *injector) fireSync(ctx context.Context, key string, data interface{}) {
+	e := Event{
+		Src:  i,
+		Type: key,
+		Data: data,
+		ctx:  ctx,
+	}
+	for inj := i; ; {
+		if inj.handlersFor(key) != nil {
+			inj.run(e)
+			return
+		}
+		p, ok := inj.parent().(*injector)
+		if !ok {
+			if inj.parent() != nil {
+				inj.parent().Events() <- e
+			}
+			return
+		}
+		inj = p
+	}
+}
+
+// OnPanic registers fn to receive the panics of the handlers run by this
+// injector, and by its children having no panic handler of their own,
+// instead of letting them crash the program. A panic is reported to the
+// panic handler of the injector running the handler if it has one, else to
+// the nearest of its parents having one; without any, the panic is not
+// recovered, which is the default. After a reported panic the remaining
+// handlers of the event still run, and Request gets an error in place of
+// the reply of the handler. Passing nil removes the panic handler.
+func (i *injector) OnPanic(fn func(e Event, recovered interface{})) {
+	i.mu.Lock()
+	i.onPanic = fn
+	i.mu.Unlock()
+}
+
+// panicHandler returns the panic handler of i or of the nearest of its
+// parents having one, or nil.
+func (i *injector) panicHandler() func(Event, interface{}) {
+	for inj, ok := i, true; ok; inj, ok = inj.parent().(*injector) {
+		inj.mu.RLock()
+		fn := inj.onPanic
+		inj.mu.RUnlock()
+		if fn != nil {
+			return fn
+		}
+	}
+	return nil
+}
+
+// invokeHandler invokes the handler h of e with ctx, reporting a panic of h
+// to the panic handler, if any, as an error.
+func (i *injector) invokeHandler(ctx context.Context, e Event, h Handler) (out []reflect.Value, err error) {
+	if report := i.panicHandler(); report != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				report(e, r)
+				out, err = nil, fmt.Errorf("inject: handler for %q panicked: %v", e.Type, r)
+			}
+		}()
+	}
+	return i.InvokeCtx(ctx, h)
+}
+
+// response is the outcome of the handlers of an event sent by Request.
+type response struct {
+	data interface{}
+	err  error
+}
+
+// handlerReply is what a replying handler returned.
+type handlerReply struct {
+	out []reflect.Value
+	err error
+}
+
+// respond turns the replies of the handlers of key into a response.
+func respond(key string, replies []handlerReply) response {
+	switch len(replies) {
+	case 0:
+		return response{err: fmt.Errorf("event %q: %w", key, ErrNoReply)}
+	case 1:
+	default:
+		return response{err: fmt.Errorf("event %q: %d handlers replied, expected exactly one", key, len(replies))}
+	}
+
+	r := replies[0]
+	if r.err != nil {
+		return response{err: r.err}
+	}
+	if last := r.out[len(r.out)-1]; last.Type() == errorType {
+		if err, _ := last.Interface().(error); err != nil {
+			return response{err: err}
+		}
+		if len(r.out) == 1 {
+			return response{}
+		}
+	}
+	return response{data: r.out[0].Interface()}
+}
+
+// Request fires an event for key carrying data, like Fire, and waits for
+// the reply of the handler dispatching it. A handler replies by returning
+// values: its first return value is the reply and, if its last return
+// value is an error, a non-nil error is returned instead. Handlers without
+// return values are still run but do not reply. Exactly one handler is
+// expected to reply: if none does Request returns ErrNoReply, if several do
+// it returns an error as well. Request returns ErrRequestTimeout if the
+// event loop does not accept the event and reply within the request
+// timeout, see WithRequestTimeout. Request must not be called from a
+// handler of the same event loop, which would wait for itself.
+func (i *injector) Request(key string, data interface{}) (interface{}, error) {
+	i.checkPayload(key, data)
+	if !i.hasHandlers(key) {
+		return nil, fmt.Errorf("event %q: %w", key, ErrNoReply)
+	}
+
+	// buffered so a late reply does not block the event loop
+	replies := make(chan response, 1)
+	e := Event{
+		Src:     i,
+		Type:    key,
+		Data:    data,
+		respond: replies,
+	}
+
+	timeout := time.NewTimer(i.requestTimeout)
+	defer timeout.Stop()
+
+	select {
+	case i.events <- e:
+	case <-timeout.C:
+		return nil, fmt.Errorf("event %q: %w", key, ErrRequestTimeout)
+	}
+
+	select {
+	case r := <-replies:
+		return r.data, r.err
+	case <-timeout.C:
+		return nil, fmt.Errorf("event %q: %w", key, ErrRequestTimeout)
+	}
+}
@@ -0,0 +1,450 @@
+package inject_test
+
+import (
+	"context"
+	"errors"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// receive waits for a value on c, failing the test after a second.
+func receive(t *testing.T, c <-chan interface{}) interface{} {
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return nil
+	}
+}
+
+func Test_InjectorDeclareEvent(t *testing.T) {
+	injector := inject.New()
+	injector.Start()
+	defer injector.Stop()
+
+	received := make(chan interface{}, 1)
+	injector.On("user.created", func(e inject.Event) {
+		received <- e.Data
+	})
+	injector.DeclareEvent("user.created", "")
+
+	injector.Fire("user.created", "bob")
+	expect(t, receive(t, received), "bob")
+
+	msg := panicMessage(func() { injector.Fire("user.created", 42) })
+	expect(t, strings.Contains(msg, `event "user.created" expects data of type string, got int`), true)
+
+	// undeclared keys accept anything
+	injector.On("anything", func(e inject.Event) {
+		received <- e.Data
+	})
+	injector.Fire("anything", 42)
+	expect(t, receive(t, received), 42)
+}
+
+func Test_InjectorDeclareEventParent(t *testing.T) {
+	parent := inject.New()
+	parent.Start()
+	defer parent.Stop()
+	parent.On("user.created", func(e inject.Event) {})
+	parent.DeclareEvent("user.created", "")
+
+	child := inject.New()
+	child.SetParent(parent)
+
+	msg := panicMessage(func() { child.Fire("user.created", 42) })
+	expect(t, strings.Contains(msg, `event "user.created" expects data of type string, got int`), true)
+
+	msg = panicMessage(func() { parent.DeclareEvent("user.deleted", nil) })
+	expect(t, msg, `inject: event "user.deleted" declared with a nil sample`)
+}
+
+func Test_InjectorOnFrom(t *testing.T) {
+	parent := inject.New()
+	child1 := inject.New()
+	child1.SetParent(parent)
+	child2 := inject.New()
+	child2.SetParent(parent)
+
+	fromChild1 := make(chan interface{}, 2)
+	all := make(chan interface{}, 2)
+	parent.OnFrom(child1, "ping", func(e inject.Event) {
+		fromChild1 <- e.Data
+	})
+	parent.On("ping", func(e inject.Event) {
+		all <- e.Data
+	})
+
+	for _, inj := range []inject.Injector{parent, child1, child2} {
+		inj.Start()
+		defer inj.Stop()
+	}
+
+	child2.Fire("ping", "child2")
+	expect(t, receive(t, all), "child2")
+	child1.Fire("ping", "child1")
+	expect(t, receive(t, all), "child1")
+
+	expect(t, receive(t, fromChild1), "child1")
+	select {
+	case v := <-fromChild1:
+		t.Errorf("unexpected event from %v", v)
+	default:
+	}
+}
+
+func Test_InjectorRequest(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&Greeter{"Jeremy"})
+	injector.On("greet", func(e inject.Event, g *Greeter) string {
+		return "Hello " + e.Data.(string) + ", I am " + g.Name
+	})
+	injector.On("greet", func(e inject.Event) {})
+	injector.On("fail", func(e inject.Event) (string, error) {
+		return "", errors.New("boom")
+	})
+	injector.On("silent", func(e inject.Event) {})
+	injector.On("twice", func(e inject.Event) int { return 1 }, func(e inject.Event) int { return 2 })
+	injector.Start()
+	defer injector.Stop()
+
+	reply, err := injector.Request("greet", "bob")
+	expect(t, err, nil)
+	expect(t, reply, "Hello bob, I am Jeremy")
+
+	_, err = injector.Request("fail", nil)
+	expect(t, err.Error(), "boom")
+
+	_, err = injector.Request("silent", nil)
+	expect(t, errors.Is(err, inject.ErrNoReply), true)
+
+	_, err = injector.Request("unknown", nil)
+	expect(t, errors.Is(err, inject.ErrNoReply), true)
+
+	_, err = injector.Request("twice", nil)
+	refute(t, err, nil)
+}
+
+func Test_InjectorRequestTimeout(t *testing.T) {
+	injector := inject.New(inject.WithRequestTimeout(20 * time.Millisecond))
+	done := make(chan bool)
+	injector.On("slow", func(e inject.Event) string {
+		<-done
+		return "too late"
+	})
+	injector.Start()
+	defer injector.Stop()
+	defer close(done)
+
+	_, err := injector.Request("slow", nil)
+	expect(t, errors.Is(err, inject.ErrRequestTimeout), true)
+}
+
+func Test_InjectorFireCtx(t *testing.T) {
+	injector := inject.New()
+	injector.Start()
+
+	started := make(chan interface{})
+	stopped := make(chan interface{})
+	skipped := make(chan interface{}, 1)
+	injector.On("download", func(ctx context.Context, e inject.Event) {
+		started <- e.Data
+		<-ctx.Done()
+		stopped <- ctx.Err()
+	}, func(e inject.Event) {
+		skipped <- e.Data
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	injector.FireCtx(ctx, "download", "file.txt")
+	expect(t, receive(t, started), "file.txt")
+	cancel()
+	expect(t, receive(t, stopped), context.Canceled)
+
+	// Stop returns once the event loop is done with the event
+	injector.Stop()
+	expect(t, len(skipped), 0)
+}
+
+func Test_InjectorFireCtxInjected(t *testing.T) {
+	injector := inject.New()
+	injector.Start()
+	defer injector.Stop()
+
+	type key struct{}
+	got := make(chan interface{})
+	injector.On("job", func(e inject.Event, ctx context.Context) {
+		got <- ctx.Value(key{})
+	}, func(e inject.Event) {
+		got <- e.Context().Value(key{})
+	})
+
+	ctx := context.WithValue(context.Background(), key{}, "request-1")
+	injector.FireCtx(ctx, "job", nil)
+	expect(t, receive(t, got), "request-1")
+	expect(t, receive(t, got), "request-1")
+}
+
+func Test_InjectorFireSync(t *testing.T) {
+	parent := inject.New()
+	child := inject.New()
+	child.SetParent(parent)
+
+	var got []string
+	parent.On("saved", func(ctx context.Context, e inject.Event) {
+		got = append(got, e.Data.(string))
+	}, func(e inject.Event) {
+		got = append(got, e.Src.Get(reflect.TypeOf("")).Interface().(string))
+	})
+	child.Map("child")
+
+	child.FireSync(context.Background(), "saved", "doc")
+	expect(t, strings.Join(got, ","), "doc,child")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	child.FireSync(ctx, "saved", "skipped")
+	expect(t, len(got), 2)
+
+	// no handlers anywhere
+	child.FireSync(context.Background(), "unknown", nil)
+}
+
+func Test_InjectorFireSyncConcurrentEvents(t *testing.T) {
+	injector := inject.New()
+	check := func(e inject.Event) {
+		if e.Data.(string) != e.Type {
+			t.Errorf("handler for %q got the event of %q", e.Type, e.Data)
+		}
+	}
+	injector.On("a", check)
+	injector.On("b", check)
+
+	done := make(chan bool)
+	for _, key := range []string{"a", "b"} {
+		go func(key string) {
+			for n := 0; n < 100; n++ {
+				injector.FireSync(context.Background(), key, key)
+			}
+			done <- true
+		}(key)
+	}
+	<-done
+	<-done
+
+	// the Event is not left mapped on the injector
+	expect(t, injector.Len(), 0)
+	expect(t, injector.Get(reflect.TypeOf(inject.Event{})).IsValid(), false)
+}
+
+func Test_InjectorOnConcurrentFire(t *testing.T) {
+	injector := inject.New()
+	injector.Start()
+	defer injector.Stop()
+
+	received := make(chan interface{}, 100)
+	injector.On("tick", func(e inject.Event) {
+		received <- e.Data
+	})
+
+	done := make(chan bool)
+	go func() {
+		for n := 0; n < 50; n++ {
+			injector.On("tock", func(e inject.Event) {})
+		}
+		done <- true
+	}()
+	for n := 0; n < 50; n++ {
+		injector.Fire("tick", n)
+	}
+	<-done
+
+	for n := 0; n < 50; n++ {
+		expect(t, receive(t, received), n)
+	}
+}
+
+func Test_InjectorStartFor(t *testing.T) {
+	injector := inject.New()
+
+	received := make(chan interface{}, 1)
+	injector.On("job", func(e inject.Event) {
+		received <- e.Data
+	})
+	expired := make(chan interface{}, 1)
+	injector.On(inject.EventLoopExpired, func(e inject.Event) {
+		expired <- e.Data
+	})
+
+	injector.StartFor(50 * time.Millisecond)
+	injector.Fire("job", 1)
+	expect(t, receive(t, received), 1)
+
+	expect(t, receive(t, expired), context.DeadlineExceeded)
+
+	// the loop is gone: Stop returns and events are not dispatched
+	injector.Stop()
+	go injector.Fire("job", 2)
+	select {
+	case <-received:
+		t.Error("event dispatched after the loop expired")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func Test_InjectorStartCtx(t *testing.T) {
+	injector := inject.New()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	expired := make(chan interface{}, 1)
+	injector.On(inject.EventLoopExpired, func(e inject.Event) {
+		expired <- e.Data
+	})
+	injector.StartCtx(ctx)
+	cancel()
+	expect(t, receive(t, expired), context.Canceled)
+}
+
+func Test_InjectorOnValidatesArgs(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&Greeter{"Jeremy"})
+
+	injector.On("valid", func(ctx context.Context, e inject.Event, g *Greeter, again inject.Event, c context.Context) {})
+	injector.On("optional", func(e inject.Event, db inject.Optional[*DB]) {})
+
+	msg := panicMessage(func() {
+		injector.On("invalid", func(e inject.Event, db *DB) {})
+	})
+	expect(t, msg, `inject handler for "invalid" takes *inject_test.DB, which cannot be resolved`)
+
+	msg = panicMessage(func() {
+		injector.OnState("invalid", func(e inject.Event, db *DB) {})
+	})
+	expect(t, msg, `inject handler for "invalid" takes *inject_test.DB, which cannot be resolved`)
+
+	// providers and parents count
+	injector.MapProvider(func() *DB { return &DB{} })
+	child := inject.New()
+	child.SetParent(injector)
+	child.On("provided", func(e inject.Event, db *DB, g *Greeter) {})
+
+	expect(t, child.Has(reflect.TypeOf(&DB{})), true)
+	expect(t, child.Has(reflect.TypeOf(3.14)), false)
+}
+
+func Test_InjectorFireSyncCtx(t *testing.T) {
+	injector := inject.New()
+
+	stopped := make(chan interface{}, 1)
+	skipped := make(chan interface{}, 1)
+	injector.On("slow", func(ctx context.Context, e inject.Event) {
+		<-ctx.Done()
+		stopped <- ctx.Err()
+	}, func(e inject.Event) {
+		skipped <- e.Data
+	})
+	var fast []string
+	injector.On("fast", func(e inject.Event) {
+		fast = append(fast, e.Data.(string))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := injector.FireSyncCtx(ctx, "slow", nil)
+	expect(t, errors.Is(err, context.DeadlineExceeded), true)
+	expect(t, err.Error(), `event "slow": context deadline exceeded`)
+	expect(t, receive(t, stopped), context.DeadlineExceeded)
+	select {
+	case <-skipped:
+		t.Error("handler run after the deadline")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	expect(t, injector.FireSyncCtx(context.Background(), "fast", "done"), nil)
+	expect(t, strings.Join(fast, ","), "done")
+
+	// a context done already runs nothing
+	err = injector.FireSyncCtx(ctx, "fast", "late")
+	refute(t, err, nil)
+	expect(t, len(fast), 1)
+}
+
+func Test_InjectorWithFrozenHandlers(t *testing.T) {
+	injector := inject.New(inject.WithFrozenHandlers())
+	received := make(chan interface{}, 1)
+	injector.On("tick", func(e inject.Event) {
+		received <- e.Data
+	})
+	injector.Start()
+	defer injector.Stop()
+
+	injector.Fire("tick", 1)
+	expect(t, receive(t, received), 1)
+
+	var err error
+	func() {
+		defer func() { err, _ = recover().(error) }()
+		injector.On("tock", func(e inject.Event) {})
+	}()
+	expect(t, errors.Is(err, inject.ErrHandlersFrozen), true)
+	expect(t, err.Error(), `inject: cannot register a handler for "tock" after Start: handlers are frozen`)
+
+	msg := panicMessage(func() { injector.OnFrom(injector, "tick", func(e inject.Event) {}) })
+	expect(t, msg, `inject: cannot register a handler for "tick" after Start: handlers are frozen`)
+
+	// handlers stay dynamic by default
+	dynamic := inject.New()
+	dynamic.Start()
+	defer dynamic.Stop()
+	dynamic.On("tick", func(e inject.Event) {})
+}
+
+func Test_InjectorOnPanic(t *testing.T) {
+	parent := inject.New()
+	child := inject.New()
+	child.SetParent(parent)
+
+	panics := make(chan interface{}, 2)
+	parent.OnPanic(func(e inject.Event, recovered interface{}) {
+		panics <- e.Type + ": " + recovered.(string)
+	})
+
+	ran := false
+	child.On("job", func(e inject.Event) {
+		panic("boom")
+	}, func(e inject.Event) {
+		ran = true
+	})
+	child.On("ask", func(e inject.Event) string {
+		panic("no answer")
+	})
+	child.Start()
+	defer child.Stop()
+
+	child.FireSync(context.Background(), "job", nil)
+	expect(t, receive(t, panics), "job: boom")
+	expect(t, ran, true)
+
+	_, err := child.Request("ask", nil)
+	expect(t, err.Error(), `inject: handler for "ask" panicked: no answer`)
+	expect(t, receive(t, panics), "ask: no answer")
+
+	// a panic handler of the child takes precedence
+	local := make(chan interface{}, 1)
+	child.OnPanic(func(e inject.Event, recovered interface{}) {
+		local <- recovered
+	})
+	child.FireSync(context.Background(), "job", nil)
+	expect(t, receive(t, local), "boom")
+	expect(t, len(panics), 0)
+
+	// without any panic handler the panic is not recovered
+	child.OnPanic(nil)
+	parent.OnPanic(nil)
+	msg := panicMessage(func() { child.FireSync(context.Background(), "job", nil) })
+	expect(t, msg, "boom")
+}
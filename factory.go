@@ -0,0 +1,91 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MapParamProvider registers fn as a factory taking runtime arguments. fn
+// must take at least one parameter and return either a single value or a
+// value and an error, e.g. func(id string, db *DB) *Session. A requested
+// func type whose results are those of fn and whose parameters are leading
+// parameters of fn, e.g. func(string) *Session, then resolves to a func
+// calling fn: the parameters of the requested func type are passed by its
+// caller, and the remaining parameters of fn are injected each time it is
+// called, from the injector the func was requested from. If such an
+// argument cannot be resolved the func returns the error when it returns
+// one, like fn, and panics otherwise.
+// It panics if fn is not a func of that shape.
+func (i *injector) MapParamProvider(fn interface{}) TypeMapper {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func || t.NumIn() == 0 || t.NumOut() < 1 || t.NumOut() > 2 || (t.NumOut() == 2 && t.Out(1) != errorType) {
+		panic(fmt.Sprintf("inject param provider must be a func taking arguments and returning T or (T, error), got %v", t))
+	}
+
+	i.mu.Lock()
+	i.factories = append(i.factories, reflect.ValueOf(fn))
+	i.mu.Unlock()
+	return i
+}
+
+// factoryFor returns the func of type t calling the factory registered with
+// MapParamProvider in i or the nearest of its parents matching t, or the
+// zero Value if there is none. The most recently registered factory of an
+// injector wins.
+func (i *injector) factoryFor(t reflect.Type) reflect.Value {
+	if t.Kind() != reflect.Func {
+		return reflect.Value{}
+	}
+	for inj, ok := i, true; ok; inj, ok = inj.parent().(*injector) {
+		inj.mu.RLock()
+		factories := inj.factories
+		inj.mu.RUnlock()
+		for n := len(factories) - 1; n >= 0; n-- {
+			if fn := factories[n]; factoryMatches(fn.Type(), t) {
+				return i.bindFactory(fn, t)
+			}
+		}
+	}
+	return reflect.Value{}
+}
+
+// factoryMatches reports whether the factory type ft can be called as a t.
+func factoryMatches(ft, t reflect.Type) bool {
+	if t.NumIn() > ft.NumIn() || t.NumOut() != ft.NumOut() || t.IsVariadic() {
+		return false
+	}
+	for n := 0; n < t.NumIn(); n++ {
+		if t.In(n) != ft.In(n) {
+			return false
+		}
+	}
+	for n := 0; n < t.NumOut(); n++ {
+		if t.Out(n) != ft.Out(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// bindFactory returns a func of type t calling fn with the arguments it is
+// called with followed by the remaining arguments of fn, resolved by i.
+func (i *injector) bindFactory(fn reflect.Value, t reflect.Type) reflect.Value {
+	ft := fn.Type()
+	return reflect.MakeFunc(t, func(args []reflect.Value) []reflect.Value {
+		in := append(make([]reflect.Value, 0, ft.NumIn()), args...)
+		for n := len(args); n < ft.NumIn(); n++ {
+			val, err := i.resolveArg(ft.In(n))
+			if err == nil && !val.IsValid() {
+				err = fmt.Errorf("Value not found for type %v", ft.In(n))
+			}
+			if err != nil {
+				if ft.NumOut() == 2 {
+					return []reflect.Value{reflect.Zero(ft.Out(0)), reflect.ValueOf(&err).Elem()}
+				}
+				panic(err)
+			}
+			in = append(in, val)
+		}
+		return fn.Call(in)
+	})
+}
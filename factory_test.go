@@ -0,0 +1,68 @@
+package inject_test
+
+import (
+	"errors"
+	"github.com/codegangsta/inject"
+	"testing"
+)
+
+type Session struct {
+	ID string
+	DB *DB
+}
+
+type SessionHandler struct {
+	NewSession func(string) *Session `inject:"t"`
+}
+
+func Test_InjectorMapParamProvider(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&DB{"postgres://"})
+	injector.MapParamProvider(func(id string, db *DB) *Session {
+		return &Session{id, db}
+	})
+
+	h := SessionHandler{}
+	expect(t, injector.Apply(&h), nil)
+	s := h.NewSession("abc")
+	expect(t, s.ID, "abc")
+	expect(t, s.DB.DSN, "postgres://")
+	// every call constructs a new value
+	refute(t, h.NewSession("abc"), s)
+
+	_, err := injector.Invoke(func(newSession func(string) *Session) {
+		expect(t, newSession("def").ID, "def")
+	})
+	expect(t, err, nil)
+
+	// a func type that does not match any factory is not found
+	_, err = injector.Invoke(func(newSession func(int) *Session) {})
+	refute(t, err, nil)
+}
+
+func Test_InjectorMapParamProviderError(t *testing.T) {
+	injector := inject.New()
+	injector.MapParamProvider(func(id string, db *DB) (*Session, error) {
+		if id == "" {
+			return nil, errors.New("empty id")
+		}
+		return &Session{id, db}, nil
+	})
+
+	_, err := injector.Invoke(func(newSession func(string) (*Session, error)) {
+		_, err := newSession("abc")
+		expect(t, err.Error(), "Value not found for type *inject_test.DB")
+
+		injector.Map(&DB{"postgres://"})
+		s, err := newSession("abc")
+		expect(t, err, nil)
+		expect(t, s.DB.DSN, "postgres://")
+
+		_, err = newSession("")
+		expect(t, err.Error(), "empty id")
+	})
+	expect(t, err, nil)
+
+	msg := panicMessage(func() { injector.MapParamProvider(func() *Session { return nil }) })
+	expect(t, msg, "inject param provider must be a func taking arguments and returning T or (T, error), got func() *inject_test.Session")
+}
@@ -0,0 +1,57 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Future defers resolving a dependency until it is used. A parameter or
+// field of type Future[T] is injected right away, without resolving T,
+// which allows wiring services referencing each other, as long as they only
+// use the reference once they are all constructed: a provider of A taking a
+// Future[B] does not need B to be constructed, even if the provider of B
+// needs A. The zero Future, not injected by an injector, resolves nothing.
+type Future[T any] struct {
+	inj *injector
+}
+
+// Get resolves T from the injector the Future was injected by, like an
+// argument of Invoke, and returns an error if it cannot be resolved yet.
+// Every call resolves T again, which returns the same value once T is
+// mapped or constructed by its provider. Get is safe for concurrent use.
+func (f Future[T]) Get() (T, error) {
+	var zero T
+	t := typeFor[T]()
+	if f.inj == nil {
+		return zero, fmt.Errorf("Future of type %v is not injected", t)
+	}
+
+	v, err := f.inj.resolveArg(t)
+	if err != nil {
+		return zero, err
+	}
+	if !v.IsValid() {
+		return zero, fmt.Errorf("Value not found for type %v", t)
+	}
+	a, _ := v.Interface().(T)
+	return a, nil
+}
+
+// future is implemented by every Future[T].
+type future interface {
+	bind(i *injector) reflect.Value
+}
+
+func (Future[T]) bind(i *injector) reflect.Value {
+	return reflect.ValueOf(Future[T]{i})
+}
+
+var futureType = reflect.TypeOf((*future)(nil)).Elem()
+
+// futureOf returns t as a future if t is a Future[T].
+func futureOf(t reflect.Type) (future, bool) {
+	if t.Kind() != reflect.Struct || !t.Implements(futureType) {
+		return nil, false
+	}
+	return reflect.Zero(t).Interface().(future), true
+}
@@ -0,0 +1,57 @@
+package inject_test
+
+import (
+	"github.com/codegangsta/inject"
+	"reflect"
+	"testing"
+)
+
+type OrderService struct {
+	Billing inject.Future[*BillingService]
+}
+
+type BillingService struct {
+	Orders *OrderService
+}
+
+func (s *OrderService) Charge() (string, error) {
+	billing, err := s.Billing.Get()
+	if err != nil {
+		return "", err
+	}
+	if billing.Orders != s {
+		return "", nil
+	}
+	return "charged", nil
+}
+
+func Test_InjectorFuture(t *testing.T) {
+	injector := inject.New()
+	injector.MapProvider(func(billing inject.Future[*BillingService]) *OrderService {
+		return &OrderService{billing}
+	})
+	injector.MapProvider(func(orders *OrderService) *BillingService {
+		return &BillingService{orders}
+	})
+
+	orders := injector.Get(reflect.TypeOf(&OrderService{})).Interface().(*OrderService)
+	charged, err := orders.Charge()
+	expect(t, err, nil)
+	expect(t, charged, "charged")
+
+	// Apply injects futures too, which fail to resolve what is missing
+	s := struct {
+		Greeter inject.Future[*Greeter] `inject:"t"`
+	}{}
+	expect(t, injector.Apply(&s), nil)
+	_, err = s.Greeter.Get()
+	expect(t, err.Error(), "Value not found for type *inject_test.Greeter")
+
+	injector.Map(&Greeter{"Jeremy"})
+	g, err := s.Greeter.Get()
+	expect(t, err, nil)
+	expect(t, g.Name, "Jeremy")
+
+	_, err = inject.Future[*Greeter]{}.Get()
+	expect(t, err.Error(), "Future of type *inject_test.Greeter is not injected")
+}
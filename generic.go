@@ -0,0 +1,252 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MapMakeChan makes a channel of T with the given buffer size and maps it as
+// chan T, chan<- T and <-chan T, so producers and consumers injected with
+// either direction share the same channel. If closeOnClose is true the
+// channel is closed by the injector's Close, after the cleanups registered
+// later, so producers must not send on it anymore by then.
+func MapMakeChan[T any](inj Injector, buffer int, closeOnClose bool) chan T {
+	c := make(chan T, buffer)
+
+	v := reflect.ValueOf(c)
+	inj.Set(v.Type(), v)
+	inj.Set(reflect.ChanOf(reflect.SendDir, v.Type().Elem()), v)
+	inj.Set(reflect.ChanOf(reflect.RecvDir, v.Type().Elem()), v)
+
+	if closeOnClose {
+		inj.OnClose(func() error {
+			close(c)
+			return nil
+		})
+	}
+	return c
+}
+
+// typeFor returns the reflect.Type of T, which may be an interface.
+func typeFor[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// valueFor returns v as a reflect.Value of its concrete type, like
+// reflect.ValueOf, so that a value mapped under an interface is stored as
+// MapTo stores it. A nil interface is kept valid as the zero T.
+func valueFor[T any](v T) reflect.Value {
+	val := reflect.ValueOf(v)
+	if !val.IsValid() {
+		return reflect.Zero(typeFor[T]())
+	}
+	return val
+}
+
+// Get resolves a T from inj like Invoke resolves an argument of type T,
+// without the reflect.Type and reflect.Value juggling of Injector.Get. It
+// returns an error if no T can be resolved or its provider failed.
+func Get[T any](inj Injector) (T, error) {
+	var zero T
+	t := typeFor[T]()
+	var v reflect.Value
+	var err error
+	if i, ok := inj.(*injector); ok {
+		v, err = i.resolveArg(t)
+	} else {
+		v = inj.Get(t)
+	}
+	if err != nil {
+		return zero, err
+	}
+	if !v.IsValid() {
+		return zero, fmt.Errorf("Value not found for type %v", t)
+	}
+	a, _ := v.Interface().(T)
+	return a, nil
+}
+
+// Map maps val as T, like Injector.Map does with its concrete type. T is
+// usually inferred from val; giving an interface, e.g. Map[io.Writer], maps
+// val as that interface instead.
+func Map[T any](inj Injector, val T) TypeMapper {
+	return inj.Set(typeFor[T](), valueFor(val))
+}
+
+// MapAs maps impl as the interface I, like Injector.MapTo without the
+// (*I)(nil) idiom, e.g. MapAs[io.Writer](inj, os.Stdout). It panics if I is
+// not an interface.
+func MapAs[I any](inj Injector, impl I) TypeMapper {
+	t := typeFor[I]()
+	if t.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("inject MapAs needs an interface, got %v", t))
+	}
+	return inj.Set(t, valueFor(impl))
+}
+
+// Unmap removes the value mapped as T from inj, see Injector.Unmap.
+func Unmap[T any](inj Injector) bool {
+	return inj.Unmap(typeFor[T]())
+}
+
+// GroupOf returns the members of the group name of inj as a []T, see
+// Injector.GetGroup.
+func GroupOf[T any](inj Injector, name string) ([]T, error) {
+	vals, err := inj.GetGroup(name, typeFor[T]())
+	if err != nil {
+		return nil, err
+	}
+	members := make([]T, len(vals))
+	for n, v := range vals {
+		members[n], _ = v.Interface().(T)
+	}
+	return members, nil
+}
+
+// resolveAs resolves the argument of type T for a provider of i
+// constructing the types on path.
+func resolveAs[T any](i *injector, path *construction) (T, error) {
+	var zero T
+	t := typeFor[T]()
+	v, err := i.resolveArgOn(t, path)
+	if err != nil {
+		return zero, err
+	}
+	if !v.IsValid() {
+		return zero, notFound(t, path)
+	}
+	a, _ := v.Interface().(T)
+	return a, nil
+}
+
+// provideFunc registers fn as the provider of R, constructing it with call
+// instead of calling fn through reflection. Injectors not created by New
+// get fn registered with MapProvider instead.
+func provideFunc[R any](inj Injector, fn interface{}, call func(i *injector, path *construction) (reflect.Value, error), opts []ProviderOption) TypeMapper {
+	i, ok := inj.(*injector)
+	if !ok {
+		return inj.MapProvider(fn, opts...)
+	}
+	i.addProvider(&provider{fn: reflect.ValueOf(fn), typ: typeFor[R](), call: call}, opts)
+	return i
+}
+
+// ProvideFunc0 registers fn as the provider of R, like MapProvider, but
+// invokes it as a typed func instead of through reflection. The
+// ProvideFuncN variants trade some API surface for cheaper constructions
+// of the most common constructor shapes: their arguments are still
+// resolved from the Type map, but neither the arguments nor the result go
+// through reflect.Value.Call.
+func ProvideFunc0[R any](inj Injector, fn func() R, opts ...ProviderOption) TypeMapper {
+	return provideFunc[R](inj, fn, func(*injector, *construction) (reflect.Value, error) {
+		return valueFor(fn()), nil
+	}, opts)
+}
+
+// ProvideFunc1 registers fn as the provider of R like ProvideFunc0,
+// injecting its argument.
+func ProvideFunc1[A, R any](inj Injector, fn func(A) R, opts ...ProviderOption) TypeMapper {
+	return provideFunc[R](inj, fn, func(i *injector, path *construction) (reflect.Value, error) {
+		a, err := resolveAs[A](i, path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return valueFor(fn(a)), nil
+	}, opts)
+}
+
+// ProvideFunc2 registers fn as the provider of R like ProvideFunc0,
+// injecting its arguments.
+func ProvideFunc2[A, B, R any](inj Injector, fn func(A, B) R, opts ...ProviderOption) TypeMapper {
+	return provideFunc[R](inj, fn, func(i *injector, path *construction) (reflect.Value, error) {
+		a, err := resolveAs[A](i, path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		b, err := resolveAs[B](i, path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return valueFor(fn(a, b)), nil
+	}, opts)
+}
+
+// ProvideFunc3 registers fn as the provider of R like ProvideFunc0,
+// injecting its arguments.
+func ProvideFunc3[A, B, C, R any](inj Injector, fn func(A, B, C) R, opts ...ProviderOption) TypeMapper {
+	return provideFunc[R](inj, fn, func(i *injector, path *construction) (reflect.Value, error) {
+		a, err := resolveAs[A](i, path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		b, err := resolveAs[B](i, path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		c, err := resolveAs[C](i, path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return valueFor(fn(a, b, c)), nil
+	}, opts)
+}
+
+// Invoke1 invokes fn with inj like Injector.InvokeErr and returns its first
+// return value as a T, along with the error fn returns as its last return
+// value, if any. It returns an error, and does not invoke fn, if fn's first
+// return value is not a T.
+func Invoke1[T any](inj Injector, fn interface{}) (T, error) {
+	var r T
+	if err := checkResults(fn, typeFor[T]()); err != nil {
+		return r, err
+	}
+	out, err := inj.InvokeErr(fn)
+	if len(out) > 0 {
+		r, _ = out[0].Interface().(T)
+	}
+	return r, err
+}
+
+// Invoke2 invokes fn with inj like Invoke1, returning its first two return
+// values as a T1 and a T2.
+func Invoke2[T1, T2 any](inj Injector, fn interface{}) (T1, T2, error) {
+	var r1 T1
+	var r2 T2
+	if err := checkResults(fn, typeFor[T1](), typeFor[T2]()); err != nil {
+		return r1, r2, err
+	}
+	out, err := inj.InvokeErr(fn)
+	if len(out) > 1 {
+		r1, _ = out[0].Interface().(T1)
+		r2, _ = out[1].Interface().(T2)
+	}
+	return r1, r2, err
+}
+
+// checkResults returns an error unless fn is a func whose leading return
+// values are assignable to types.
+func checkResults(fn interface{}, types ...reflect.Type) error {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		return fmt.Errorf("Invoke expects a func, got %v", t)
+	}
+	for n, want := range types {
+		if n >= t.NumOut() || !t.Out(n).AssignableTo(want) {
+			return fmt.Errorf("Return value %d of %v is not a %v", n, t, want)
+		}
+	}
+	return nil
+}
+
+// OnData registers handler for the events fired for key on inj, see
+// Injector.On, receiving their data as a T. Events whose data is not a T
+// are skipped, as are those for which guard, unless it is nil, returns
+// false.
+func OnData[T any](inj Injector, key string, guard func(T) bool, handler func(T)) {
+	inj.On(key, func(e Event) {
+		data, ok := e.Data.(T)
+		if ok && (guard == nil || guard(data)) {
+			handler(data)
+		}
+	})
+}
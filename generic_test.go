@@ -0,0 +1,225 @@
+package inject_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"testing"
+)
+
+func Test_MapMakeChan(t *testing.T) {
+	injector := inject.New()
+	c := inject.MapMakeChan[string](injector, 1, true)
+
+	_, err := injector.Invoke(func(out chan<- string) {
+		out <- "hello"
+	})
+	expect(t, err, nil)
+
+	_, err = injector.Invoke(func(in <-chan string) {
+		expect(t, <-in, "hello")
+	})
+	expect(t, err, nil)
+
+	_, err = injector.Invoke(func(both chan string) {
+		expect(t, both, c)
+	})
+	expect(t, err, nil)
+
+	expect(t, injector.Close(), nil)
+	_, ok := <-c
+	expect(t, ok, false)
+}
+
+func Test_MapMakeChanNoClose(t *testing.T) {
+	injector := inject.New()
+	c := inject.MapMakeChan[int](injector, 1, false)
+
+	expect(t, injector.Close(), nil)
+	c <- 1
+	expect(t, <-c, 1)
+}
+
+// wrappedInjector is an Injector implementation other than the one New
+// returns.
+type wrappedInjector struct {
+	inject.Injector
+}
+
+func Test_MapMakeChanWrappedInjector(t *testing.T) {
+	injector := wrappedInjector{inject.New()}
+	c := inject.MapMakeChan[int](injector, 0, true)
+
+	expect(t, injector.Close(), nil)
+	_, ok := <-c
+	expect(t, ok, false)
+}
+
+func Test_ProvideFunc(t *testing.T) {
+	injector := inject.New()
+	inject.ProvideFunc0(injector, func() *Config { return &Config{"sqlite://"} })
+	inject.ProvideFunc1(injector, func(c *Config) *DB { return &DB{c.DSN} })
+	inject.ProvideFunc2(injector, func(db *DB, c *Config) *Cache {
+		expect(t, db.DSN, c.DSN)
+		return &Cache{db}
+	})
+	inject.ProvideFunc3(injector, func(db *DB, c *Cache, s fmt.Stringer) *UserRepo {
+		expect(t, c.DB, db)
+		expect(t, s.String(), "Hello, My name isJeremy")
+		return &UserRepo{db}
+	})
+	injector.MapTo(&Greeter{"Jeremy"}, (*fmt.Stringer)(nil))
+
+	repo := injector.Get(reflect.TypeOf(&UserRepo{}))
+	expect(t, repo.IsValid(), true)
+	expect(t, repo.Interface().(*UserRepo).DB.DSN, "sqlite://")
+	// constructed once
+	expect(t, injector.Get(reflect.TypeOf(&UserRepo{})).Interface(), repo.Interface())
+	expect(t, injector.Get(reflect.TypeOf(&DB{})).Interface(), repo.Interface().(*UserRepo).DB)
+}
+
+func Test_ProvideFuncInterfaceAndMissing(t *testing.T) {
+	injector := inject.New()
+	inject.ProvideFunc0(injector, func() fmt.Stringer { return nil })
+	v := injector.Get(inject.InterfaceOf((*fmt.Stringer)(nil)))
+	expect(t, v.IsValid(), true)
+	expect(t, v.IsNil(), true)
+
+	inject.ProvideFunc1(injector, func(c *Config) *DB { return &DB{c.DSN} })
+	_, err := injector.Invoke(func(db *DB) {})
+	refute(t, err, nil)
+	expect(t, err.Error(), "Provider for type *inject_test.DB failed: Value not found for type *inject_test.Config, needed by *inject_test.DB")
+
+	// other Injector implementations fall back to MapProvider
+	wrapped := wrappedInjector{inject.New()}
+	wrapped.Map(&Config{"mysql://"})
+	inject.ProvideFunc1[*Config](wrapped, func(c *Config) *DB { return &DB{c.DSN} })
+	expect(t, wrapped.Get(reflect.TypeOf(&DB{})).Interface().(*DB).DSN, "mysql://")
+}
+
+// BenchmarkProviderReflective and BenchmarkProviderTyped measure the first
+// Get of a provided type, which constructs it.
+func BenchmarkProviderReflective(b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		injector := inject.New()
+		injector.Map(&Config{})
+		injector.MapProvider(func(c *Config) *DB { return &DB{c.DSN} })
+		b.StartTimer()
+
+		injector.Get(reflect.TypeOf(&DB{}))
+	}
+}
+
+func BenchmarkProviderTyped(b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		injector := inject.New()
+		injector.Map(&Config{})
+		inject.ProvideFunc1(injector, func(c *Config) *DB { return &DB{c.DSN} })
+		b.StartTimer()
+
+		injector.Get(reflect.TypeOf(&DB{}))
+	}
+}
+
+type OrderPlaced struct {
+	ID    int
+	Total float64
+}
+
+func Test_OnData(t *testing.T) {
+	injector := inject.New()
+
+	var large []int
+	inject.OnData(injector, "order", func(o OrderPlaced) bool {
+		return o.Total >= 100
+	}, func(o OrderPlaced) {
+		large = append(large, o.ID)
+	})
+	var all []int
+	inject.OnData(injector, "order", nil, func(o OrderPlaced) {
+		all = append(all, o.ID)
+	})
+
+	ctx := context.Background()
+	injector.FireSync(ctx, "order", OrderPlaced{1, 250})
+	injector.FireSync(ctx, "order", OrderPlaced{2, 10})
+	injector.FireSync(ctx, "order", "not an order")
+	injector.FireSync(ctx, "order", &OrderPlaced{3, 500})
+
+	expect(t, fmt.Sprint(large), "[1]")
+	expect(t, fmt.Sprint(all), "[1 2]")
+}
+
+func Test_GenericGetMap(t *testing.T) {
+	injector := inject.New()
+	inject.Map(injector, &Config{"sqlite://"})
+	inject.MapAs[fmt.Stringer](injector, &Greeter{"Jeremy"})
+	inject.ProvideFunc1(injector, func(c *Config) *DB { return &DB{c.DSN} })
+
+	db, err := inject.Get[*DB](injector)
+	expect(t, err, nil)
+	expect(t, db.DSN, "sqlite://")
+
+	s, err := inject.Get[fmt.Stringer](injector)
+	expect(t, err, nil)
+	expect(t, s.String(), "Hello, My name isJeremy")
+
+	_, err = inject.Get[*UserRepo](injector)
+	refute(t, err, nil)
+	expect(t, err.Error(), "Value not found for type *inject_test.UserRepo")
+
+	expect(t, panicMessage(func() { inject.MapAs[*Greeter](injector, &Greeter{}) }), "inject MapAs needs an interface, got *inject_test.Greeter")
+}
+
+func Test_GenericMapAsLikeMapTo(t *testing.T) {
+	stringer := inject.InterfaceOf((*fmt.Stringer)(nil))
+	generic, classic := inject.New(), inject.New()
+	inject.MapAs[fmt.Stringer](generic, &Greeter{"Jeremy"})
+	classic.MapTo(&Greeter{"Jeremy"}, (*fmt.Stringer)(nil))
+
+	for _, inj := range []inject.Injector{generic, classic} {
+		v := inj.Get(stringer)
+		expect(t, v.Type(), reflect.TypeOf(&Greeter{}))
+		expect(t, inj.Get(reflect.TypeOf(&Greeter{})).IsValid(), true)
+		expect(t, len(inj.GetAll(stringer)), 1)
+	}
+	expect(t, generic.Dump(), classic.Dump())
+
+	// a nil interface is still mapped
+	inject.MapAs[fmt.Stringer](generic, nil)
+	s, err := inject.Get[fmt.Stringer](generic)
+	expect(t, err, nil)
+	expect(t, s, nil)
+}
+
+func Test_GenericInvoke(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&Config{"sqlite://"})
+
+	db, err := inject.Invoke1[*DB](injector, func(c *Config) *DB { return &DB{c.DSN} })
+	expect(t, err, nil)
+	expect(t, db.DSN, "sqlite://")
+
+	s, err := inject.Invoke1[fmt.Stringer](injector, func() (*Greeter, error) { return &Greeter{"Jeremy"}, nil })
+	expect(t, err, nil)
+	expect(t, s.String(), "Hello, My name isJeremy")
+
+	db, c, err := inject.Invoke2[*DB, *Config](injector, func(c *Config) (*DB, *Config, error) {
+		return nil, c, errors.New("boom")
+	})
+	expect(t, err.Error(), "boom")
+	expect(t, db == nil, true)
+	expect(t, c.DSN, "sqlite://")
+
+	_, err = inject.Invoke1[*DB](injector, func(c *Config) *Config { return c })
+	expect(t, err.Error(), "Return value 0 of func(*inject_test.Config) *inject_test.Config is not a *inject_test.DB")
+
+	_, err = inject.Invoke1[*DB](inject.New(), func(c *Config) *DB { return nil })
+	expect(t, err.Error(), "Value not found for type *inject_test.Config")
+}
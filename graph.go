@@ -0,0 +1,166 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// edge is a dependency of the graph rendered by Graph.
+type edge struct {
+	from, to string
+	binding  bool
+	handler  bool
+}
+
+// Graph returns the dependency graph of the injector and its parents in
+// the DOT language of Graphviz, e.g. for `dot -Tsvg`. Its nodes are the
+// types mapped, provided or needed by a provider, interfaces being drawn
+// as boxes. A solid edge goes from each argument of a provider to the type
+// it provides; a dashed edge goes from a concrete type to an interface it
+// is bound to, with MapTo or the As provider option. Edges that are part of
+// a dependency cycle are drawn in red. Events having handlers registered
+// with On or OnFrom are drawn as diamonds named "event <key>", with a dotted
+// edge from each type their handlers take, other than Event and
+// context.Context. Each level of the hierarchy is drawn as a cluster,
+// "injector 0" being this injector, "injector 1" its parent and so on,
+// holding the types and events registered there, at the nearest level if
+// several register them; a bold edge goes from each cluster to the cluster
+// of its parent. Types only needed by a provider or a handler are drawn
+// outside the clusters. Nodes and edges are sorted by name, so the output
+// only changes when the bindings do.
+func (i *injector) Graph() string {
+	nodes := make(map[string]reflect.Type)
+	levels := make(map[string]int)
+	edges := make(map[edge]bool)
+	node := func(t reflect.Type) string {
+		nodes[t.String()] = t
+		return t.String()
+	}
+	level := 0
+	// register adds name to the cluster of this level unless a nearer one
+	// has it.
+	register := func(name string) string {
+		if _, ok := levels[name]; !ok {
+			levels[name] = level
+		}
+		return name
+	}
+
+	for inj, ok := i, true; ok; inj, ok = inj.parent().(*injector) {
+		inj.mu.RLock()
+		for _, k := range inj.order {
+			register(node(k))
+			v := inj.values[k]
+			if v.Kind() == reflect.Interface {
+				v = v.Elem()
+			}
+			if k.Kind() == reflect.Interface && v.IsValid() && v.Type() != k {
+				edges[edge{register(node(v.Type())), k.String(), true, false}] = true
+			}
+		}
+		for key, handlers := range inj.handlers {
+			event := register("event " + key)
+			nodes[event] = nil
+			for _, h := range handlers {
+				if sh, ok := h.(sourceHandler); ok {
+					h = sh.handler
+				}
+				for _, arg := range argTypes(reflect.TypeOf(h)) {
+					if arg != eventType && arg != contextType {
+						edges[edge{node(arg), event, false, true}] = true
+					}
+				}
+			}
+		}
+		for k, p := range inj.providers {
+			if k != p.typ {
+				edges[edge{register(node(p.typ)), register(node(k)), true, false}] = true
+				continue
+			}
+			register(node(p.typ))
+			for _, arg := range argTypes(p.fn.Type()) {
+				edges[edge{node(arg), node(p.typ), false, false}] = true
+			}
+		}
+		inj.mu.RUnlock()
+		level++
+	}
+	next := make(map[string][]string)
+	for e := range edges {
+		next[e.from] = append(next[e.from], e.to)
+	}
+	// reaches reports whether to can be reached from from.
+	reaches := func(from, to string) bool {
+		seen := map[string]bool{from: true}
+		stack := []string{from}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if n == to {
+				return true
+			}
+			for _, m := range next[n] {
+				if !seen[m] {
+					seen[m] = true
+					stack = append(stack, m)
+				}
+			}
+		}
+		return false
+	}
+
+	clusters := make([][]string, level)
+	var lines []string
+	for name, t := range nodes {
+		attrs := ""
+		switch {
+		case t == nil:
+			attrs = " [shape=diamond]"
+		case t.Kind() == reflect.Interface:
+			attrs = " [shape=box]"
+		}
+		if n, ok := levels[name]; ok {
+			clusters[n] = append(clusters[n], fmt.Sprintf("\t\t%q%s;", name, attrs))
+		} else {
+			lines = append(lines, fmt.Sprintf("\t%q%s;", name, attrs))
+		}
+	}
+	sort.Strings(lines)
+
+	var out []string
+	for n, cluster := range clusters {
+		sort.Strings(cluster)
+		out = append(out, fmt.Sprintf("\tsubgraph cluster_%d {", n),
+			fmt.Sprintf("\t\tlabel=\"injector %d\";", n),
+			fmt.Sprintf("\t\t\"injector %d\" [shape=point, style=invis];", n))
+		out = append(append(out, cluster...), "\t}")
+	}
+
+	var edgeLines []string
+	for e := range edges {
+		var attrs []string
+		if e.binding {
+			attrs = append(attrs, "style=dashed")
+		}
+		if e.handler {
+			attrs = append(attrs, "style=dotted")
+		}
+		if reaches(e.to, e.from) {
+			attrs = append(attrs, "color=red")
+		}
+		line := fmt.Sprintf("\t%q -> %q", e.from, e.to)
+		if len(attrs) > 0 {
+			line += " [" + strings.Join(attrs, ", ") + "]"
+		}
+		edgeLines = append(edgeLines, line+";")
+	}
+	for n := 1; n < level; n++ {
+		edgeLines = append(edgeLines, fmt.Sprintf("\t\"injector %d\" -> \"injector %d\" [ltail=cluster_%d, lhead=cluster_%d, style=bold];", n-1, n, n-1, n))
+	}
+	sort.Strings(edgeLines)
+
+	out = append(append(out, lines...), edgeLines...)
+	return "digraph inject {\n\tcompound=true;\n" + strings.Join(out, "\n") + "\n}\n"
+}
@@ -0,0 +1,108 @@
+package inject_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/codegangsta/inject"
+	"io"
+	"testing"
+)
+
+type graphA struct{}
+type graphB struct{}
+
+func Test_InjectorGraph(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&DB{"postgres://"})
+	injector.MapTo(&bytes.Buffer{}, (*io.Writer)(nil))
+	injector.MapProvider(func(db *DB, w io.Writer) *UserRepo { return &UserRepo{db} })
+	injector.MapProvider(func(r *UserRepo) *rwBuffer { return &rwBuffer{} }, inject.As((*io.Reader)(nil)))
+	// a cycle still renders
+	injector.MapProvider(func(b graphB) graphA { return graphA{} })
+	injector.MapProvider(func(a graphA) graphB { return graphB{} })
+
+	expect(t, injector.Graph(), `digraph inject {
+	compound=true;
+	subgraph cluster_0 {
+		label="injector 0";
+		"injector 0" [shape=point, style=invis];
+		"*bytes.Buffer";
+		"*inject_test.DB";
+		"*inject_test.UserRepo";
+		"*inject_test.rwBuffer";
+		"inject_test.graphA";
+		"inject_test.graphB";
+		"io.Reader" [shape=box];
+		"io.Writer" [shape=box];
+	}
+	"*bytes.Buffer" -> "io.Writer" [style=dashed];
+	"*inject_test.DB" -> "*inject_test.UserRepo";
+	"*inject_test.UserRepo" -> "*inject_test.rwBuffer";
+	"*inject_test.rwBuffer" -> "io.Reader" [style=dashed];
+	"inject_test.graphA" -> "inject_test.graphB" [color=red];
+	"inject_test.graphB" -> "inject_test.graphA" [color=red];
+	"io.Writer" -> "*inject_test.UserRepo";
+}
+`)
+
+	// the output is deterministic
+	expect(t, injector.Graph(), injector.Graph())
+}
+
+func Test_InjectorGraphHandlers(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&DB{"postgres://"})
+	injector.On("saved", func(ctx context.Context, e inject.Event, db *DB) {})
+	injector.OnFrom(injector, "deleted", func(e inject.Event, db *DB) {})
+
+	expect(t, injector.Graph(), `digraph inject {
+	compound=true;
+	subgraph cluster_0 {
+		label="injector 0";
+		"injector 0" [shape=point, style=invis];
+		"*inject_test.DB";
+		"event deleted" [shape=diamond];
+		"event saved" [shape=diamond];
+	}
+	"*inject_test.DB" -> "event deleted" [style=dotted];
+	"*inject_test.DB" -> "event saved" [style=dotted];
+}
+`)
+}
+
+func Test_InjectorGraphHierarchy(t *testing.T) {
+	app := inject.New()
+	app.Map(&DB{"postgres://"})
+	app.Map("app")
+	request := inject.New()
+	request.SetParent(app)
+	request.Map("request")
+	request.MapProvider(func(db *DB, c *Config) *UserRepo { return &UserRepo{db} })
+	handler := inject.New()
+	handler.SetParent(request)
+
+	expect(t, handler.Graph(), `digraph inject {
+	compound=true;
+	subgraph cluster_0 {
+		label="injector 0";
+		"injector 0" [shape=point, style=invis];
+	}
+	subgraph cluster_1 {
+		label="injector 1";
+		"injector 1" [shape=point, style=invis];
+		"*inject_test.UserRepo";
+		"string";
+	}
+	subgraph cluster_2 {
+		label="injector 2";
+		"injector 2" [shape=point, style=invis];
+		"*inject_test.DB";
+	}
+	"*inject_test.Config";
+	"*inject_test.Config" -> "*inject_test.UserRepo";
+	"*inject_test.DB" -> "*inject_test.UserRepo";
+	"injector 0" -> "injector 1" [ltail=cluster_0, lhead=cluster_1, style=bold];
+	"injector 1" -> "injector 2" [ltail=cluster_1, lhead=cluster_2, style=bold];
+}
+`)
+}
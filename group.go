@@ -0,0 +1,105 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// groupMember is a value contributed to a group, either mapped with
+// MapGroup or constructed on demand by a provider registered with the Group
+// option.
+type groupMember struct {
+	val reflect.Value
+	p   *provider
+}
+
+// MapGroup adds val to the group name, e.g. "routes" or "migrations". The
+// members of a group are resolved together as a slice, see GetGroup, and
+// unlike the implementors collected by GetAll they may share a concrete
+// type. Group members live apart from the Type map.
+func (i *injector) MapGroup(name string, val interface{}) TypeMapper {
+	i.addMember(name, &groupMember{val: reflect.ValueOf(val)})
+	return i
+}
+
+// Group registers a provider as a member of the group name instead of as
+// the provider of its type: it is invoked, once, the first time the group is
+// resolved, and the value it constructs joins the group without being
+// mapped. Other options apply as for any provider, e.g. a Transient member
+// is constructed anew each time the group is resolved. The As option has no
+// effect on group members.
+func Group(name string) ProviderOption {
+	return func(p *provider) {
+		p.group = name
+	}
+}
+
+// addMember appends m to the group name of i.
+func (i *injector) addMember(name string, m *groupMember) {
+	i.mu.Lock()
+	i.groups[name] = append(i.groups[name], m)
+	i.mu.Unlock()
+}
+
+// GetGroup returns the members of the group name, those of this injector
+// first, in registration order, then those of its parents, constructing the
+// members contributed by providers that have not been constructed yet. The
+// slice is empty, but not nil, if the group has no members. It returns an
+// error if a member cannot be constructed or is not assignable to t.
+func (i *injector) GetGroup(name string, t reflect.Type) ([]reflect.Value, error) {
+	return i.getGroup(name, t, nil)
+}
+
+// getGroup returns the members of the group name like GetGroup, for a
+// resolution constructing the types on path, if any.
+func (i *injector) getGroup(name string, t reflect.Type, path *construction) ([]reflect.Value, error) {
+	vals := []reflect.Value{}
+	for inj, ok := i, true; ok; inj, ok = inj.parent().(*injector) {
+		inj.mu.RLock()
+		members := append([]*groupMember(nil), inj.groups[name]...)
+		inj.mu.RUnlock()
+
+		for _, m := range members {
+			v, err := m.get(inj, path)
+			if err != nil {
+				return nil, fmt.Errorf("Group %q: %w", name, err)
+			}
+			if !v.IsValid() || !v.Type().AssignableTo(t) {
+				return nil, fmt.Errorf("Group %q has a member of type %v, which is not assignable to %v", name, typeOf(v), t)
+			}
+			vals = append(vals, v)
+		}
+	}
+	return vals, nil
+}
+
+// get returns the value of m, constructing it with i on path, like any
+// provided value, if it comes from a provider.
+func (m *groupMember) get(i *injector, path *construction) (reflect.Value, error) {
+	if m.p == nil {
+		return m.val, nil
+	}
+	return i.provide(m.p, path)
+}
+
+// groupName returns the group of a `group:` tag, e.g. `inject:"group:routes"`.
+func groupName(name string) (string, bool) {
+	if !strings.HasPrefix(name, "group:") {
+		return "", false
+	}
+	return strings.TrimPrefix(name, "group:"), true
+}
+
+// getGroupSlice returns the members of the group name as a slice of type t,
+// for a resolution constructing the types on path, if any.
+func (i *injector) getGroupSlice(t reflect.Type, name string, path *construction) (reflect.Value, error) {
+	if t.Kind() != reflect.Slice {
+		return reflect.Value{}, fmt.Errorf("Group %q needs a slice, got %v", name, t)
+	}
+	vals, err := i.getGroup(name, t.Elem(), path)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return makeSlice(t, vals), nil
+}
@@ -0,0 +1,104 @@
+package inject_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type GroupRoute struct {
+	Path string
+}
+
+type GroupRouter struct {
+	Routes []*GroupRoute `inject:"group:routes"`
+	None   []*GroupRoute `inject:"group:none"`
+}
+
+func Test_InjectorGroup(t *testing.T) {
+	parent := inject.New()
+	parent.MapGroup("routes", &GroupRoute{"/health"})
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.Map(&Config{"/users"})
+	injector.MapGroup("routes", &GroupRoute{"/"})
+	calls := 0
+	injector.MapProvider(func(c *Config) *GroupRoute {
+		calls++
+		return &GroupRoute{c.DSN}
+	}, inject.Group("routes"))
+	expect(t, calls, 0)
+
+	r := GroupRouter{}
+	expect(t, injector.Apply(&r), nil)
+	var paths []string
+	for _, route := range r.Routes {
+		paths = append(paths, route.Path)
+	}
+	expect(t, strings.Join(paths, ","), "/,/users,/health")
+	expect(t, r.None != nil && len(r.None) == 0, true)
+
+	// group members are not in the Type map and are constructed once
+	expect(t, injector.Get(reflect.TypeOf(&GroupRoute{})).IsValid(), false)
+	routes, err := inject.GroupOf[*GroupRoute](injector, "routes")
+	expect(t, err, nil)
+	expect(t, len(routes), 3)
+	expect(t, routes[1], r.Routes[1])
+	expect(t, calls, 1)
+
+	// members of an interface group may have different types
+	injector.MapGroup("stringers", &Greeter{"Jeremy"})
+	injector.MapGroup("stringers", &Greeter{"Tom"})
+	stringers, err := inject.GroupOf[fmt.Stringer](injector, "stringers")
+	expect(t, err, nil)
+	expect(t, stringers[1].String(), "Hello, My name isTom")
+	_, err = injector.GetGroup("stringers", reflect.TypeOf(&GroupRoute{}))
+	expect(t, err.Error(), `Group "stringers" has a member of type *inject_test.Greeter, which is not assignable to *inject_test.GroupRoute`)
+}
+
+func Test_InjectorGroupErrors(t *testing.T) {
+	injector := inject.New()
+	boom := errors.New("boom")
+	injector.MapProvider(func() (*GroupRoute, error) { return nil, boom }, inject.Group("routes"))
+
+	err := injector.Apply(&GroupRouter{})
+	expect(t, errors.Is(err, boom), true)
+	expect(t, err.Error(), `Field Routes: Group "routes": Provider for type *inject_test.GroupRoute failed: boom`)
+
+	err = injector.Apply(&struct {
+		Route *GroupRoute `inject:"group:routes"`
+	}{})
+	expect(t, err.Error(), `Field Route: Group "routes" needs a slice, got *inject_test.GroupRoute`)
+}
+
+type RouterParams struct {
+	inject.In
+	Routes []*GroupRoute `inject:"group:routes"`
+}
+
+func Test_InjectorGroupProviderOptions(t *testing.T) {
+	injector := inject.New()
+	calls := 0
+	injector.MapProvider(func() *GroupRoute {
+		calls++
+		return &GroupRoute{"/"}
+	}, inject.Group("routes"), inject.Transient())
+
+	for n := 0; n < 2; n++ {
+		_, err := injector.GetGroup("routes", reflect.TypeOf(&GroupRoute{}))
+		expect(t, err, nil)
+	}
+	expect(t, calls, 2)
+
+	// members are constructed on the path of the resolution needing them
+	cyclic := inject.New()
+	cyclic.MapProvider(func(p RouterParams) *GroupRouter { return &GroupRouter{Routes: p.Routes} })
+	cyclic.MapProvider(func(r *GroupRouter) *GroupRoute { return &GroupRoute{} }, inject.Group("routes"))
+	_, err := inject.Get[*GroupRouter](cyclic)
+	expect(t, errors.Is(err, inject.ErrCircularDependency), true)
+	expect(t, strings.Contains(err.Error(), "*inject_test.GroupRouter -> *inject_test.GroupRoute -> *inject_test.GroupRouter"), true)
+}
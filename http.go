@@ -0,0 +1,47 @@
+package inject
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// HTTPHandler returns an http.Handler invoking handler, a func taking any
+// dependencies, with a child of inj created for each request, sharing the
+// options inj was created with. The child has
+// the *http.Request, the http.ResponseWriter and the context of the request
+// mapped, the latter as context.Context, as well as itself as the Injector,
+// and resolves everything else from inj, so values mapped during a request
+// stay in its scope. The child is closed once handler returns, running the
+// cleanups registered with it. If a dependency of handler cannot be resolved
+// the request fails with 500 Internal Server Error, without handler being
+// invoked. It panics if handler is not a func.
+func HTTPHandler(inj Injector, handler interface{}) http.Handler {
+	if t := reflect.TypeOf(handler); t == nil || t.Kind() != reflect.Func {
+		panic(fmt.Sprintf("inject HTTP handler must be a func, got %v", t))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		child := newChild(inj)
+		defer child.Close()
+
+		child.MapTo(child, (*Injector)(nil))
+		child.Map(r)
+		child.MapTo(w, (*http.ResponseWriter)(nil))
+		child.MapTo(r.Context(), (*context.Context)(nil))
+		if _, err := child.Invoke(handler); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	})
+}
+
+// newChild returns a child of inj sharing its options, or a plain injector
+// with inj as its parent if inj was not created by New.
+func newChild(inj Injector) Injector {
+	if i, ok := inj.(*injector); ok {
+		return i.child()
+	}
+	child := New()
+	child.SetParent(inj)
+	return child
+}
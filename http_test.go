@@ -0,0 +1,63 @@
+package inject_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/codegangsta/inject"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type requestKey struct{}
+
+func Test_HTTPHandler(t *testing.T) {
+	app := inject.New()
+	app.Map(&DB{"postgres://"})
+
+	closed := 0
+	handler := inject.HTTPHandler(app, func(w http.ResponseWriter, r *http.Request, ctx context.Context, db *DB, scope inject.Injector) {
+		scope.OnClose(func() error {
+			closed++
+			return nil
+		})
+		fmt.Fprintf(w, "%s %s %v %s", r.Method, r.URL.Path, ctx.Value(requestKey{}), db.DSN)
+	})
+
+	r := httptest.NewRequest("GET", "/users", nil)
+	r = r.WithContext(context.WithValue(r.Context(), requestKey{}, "req-1"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	expect(t, w.Code, http.StatusOK)
+	expect(t, w.Body.String(), "GET /users req-1 postgres://")
+	expect(t, closed, 1)
+	// nothing leaks into the parent
+	_, err := app.Invoke(func(r *http.Request) {})
+	refute(t, err, nil)
+}
+
+func Test_HTTPHandlerMissingDependency(t *testing.T) {
+	called := false
+	handler := inject.HTTPHandler(inject.New(), func(db *DB) {
+		called = true
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	expect(t, w.Code, http.StatusInternalServerError)
+	expect(t, called, false)
+
+	expect(t, panicMessage(func() { inject.HTTPHandler(inject.New(), "not a func") }), "inject HTTP handler must be a func, got string")
+}
+
+func Test_HTTPHandlerOptions(t *testing.T) {
+	app := inject.New(inject.WithNoOverride())
+	var msg string
+	handler := inject.HTTPHandler(app, func(scope inject.Injector) {
+		scope.Map("first")
+		msg = panicMessage(func() { scope.Map("second") })
+	})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	expect(t, msg, "inject: type string is already mapped, use Override to replace it")
+}
@@ -2,9 +2,15 @@
 package inject
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
-	"github.com/bino7/inject"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 /*type Injectors interface {
@@ -13,6 +19,8 @@ import (
 
 // Injector represents an interface for mapping and injecting dependencies into structs
 // and function arguments.
+// The injector returned by New is safe for concurrent use: values can be
+// mapped, resolved and reparented while the event loop runs handlers.
 type Injector interface {
 	/*Injectors*/
 
@@ -21,23 +29,108 @@ type Injector interface {
 	TypeMapper
 	// SetParent sets the parent of the injector. If the injector cannot find a
 	// dependency in its Type map it will check its parent before returning an
-	// error.
+	// error. Bindings of the child, including implementors of an interface,
+	// always take precedence over those of the parent.
 	SetParent(Injector)
+	// InterceptFields registers a FieldInterceptor consulted by Apply for every
+	// field it injects, including Injector and func() []I fields.
+	InterceptFields(FieldInterceptor)
+	// Graph returns the dependency graph of the providers and interface
+	// bindings of the injector and its parents in the DOT language.
+	Graph() string
+	// Dump returns the values mapped in the injector and its parents, one
+	// per line, without revealing them unless created WithDumpValues.
+	Dump() string
+	// Validate reports the dependencies of the providers and handlers of the
+	// injector and its parents, and of the structs provided, that cannot be
+	// resolved, without constructing anything.
+	Validate(...interface{}) []error
+	// OnPanic registers a func receiving the events whose handlers panic,
+	// along with the recovered value, for this injector and its children.
+	OnPanic(func(e Event, recovered interface{}))
+	// OnMiss registers a callback invoked with the requested type whenever Get
+	// fails to resolve it, after the parents have been checked. Invoke and Apply
+	// only report a miss if no default supplier provides the type either. It
+	// only observes resolution and does not change its outcome. Passing nil
+	// removes it.
+	OnMiss(func(reflect.Type))
+	// InvokeAndMap invokes a function like Invoke and maps each of its non-error
+	// return values by type. It returns the error the function returned, if any.
+	InvokeAndMap(interface{}) error
+	// ProvideCleanup invokes a constructor returning (T, func(), error), maps T
+	// and registers the cleanup func to be run by Close.
+	ProvideCleanup(interface{}) error
+	// OnClose registers a teardown func to be run by Close.
+	OnClose(func() error)
+	// Close runs the cleanups registered with this injector, not those of its
+	// parents, in reverse registration order.
+	Close() error
+	// StartHooks calls OnStart on the mapped values implementing Starter, in
+	// dependency order, and StopHooks calls OnStop on the values implementing
+	// Stopper in reverse order.
+	StartHooks(ctx context.Context) error
+	StopHooks(ctx context.Context) error
+	// Export snapshots the mapped values implementing Serializable.
+	Export() ([]byte, error)
+	// Import maps every value of a snapshot created by Export.
+	Import([]byte) error
+	// Start runs the start hooks and starts the event loop, and Stop stops
+	// the event loop and runs the stop hooks, see StartHooks and StopHooks.
 	Start()
+	// StartCtx starts the event loop like Start and stops it once ctx is done.
+	StartCtx(ctx context.Context)
+	// StartFor starts the event loop like Start and stops it after d.
+	StartFor(d time.Duration)
 	Stop()
-	Events() chan inject.Event
+	Events() chan<- Event
 	On(key string, handlers ...Handler)
+	// OnFrom registers a handler for key that only runs for events fired by src.
+	OnFrom(src Injector, key string, handler Handler)
 	Fire(key string, data interface{})
+	// FireCtx fires an event for key like Fire, carrying ctx to its handlers.
+	FireCtx(ctx context.Context, key string, data interface{})
+	// FireSync runs the handlers of an event for key carrying data and ctx
+	// before it returns, instead of sending it to the event loop.
+	FireSync(ctx context.Context, key string, data interface{})
+	// FireSyncCtx runs the handlers like FireSync but returns an error once
+	// ctx is done instead of waiting for them.
+	FireSyncCtx(ctx context.Context, key string, data interface{}) error
+	// Request fires an event for key and waits for the reply of its handler.
+	Request(key string, data interface{}) (interface{}, error)
+	// DeclareEvent declares the type of the data fired for key, taken from
+	// sample. Fire panics if it is given data of another type for that key.
+	DeclareEvent(key string, sample interface{})
+	// SetState sets the latest value of the state for key and delivers it to
+	// the handlers registered with OnState before returning.
+	SetState(key string, value interface{})
+	// OnState registers a handler for the state of key, invoked at once with
+	// its current value, if any, and then on every change.
+	OnState(key string, handler Handler)
+	// Namespace returns a view of the injector prefixing every event key with
+	// prefix, so modules sharing the injector do not collide on event keys.
+	Namespace(prefix string) Injector
 }
 
 // Applicator represents an interface for mapping dependencies to a struct.
 type Applicator interface {
 	// Maps dependencies in the Type map to each field in the struct
 	// that is tagged with 'inject'. Returns an error if the injection
-	// fails.
+	// fails, joining the failures of every field that cannot be injected.
 	Apply(interface{}) error
+	// Like Apply, but returns the failures as a slice, one per field,
+	// instead of joining them. The result is empty if the injection
+	// succeeds.
+	ApplyAll(interface{}) []error
+	// Like Apply, but the fields named in the map provided are set to the
+	// values given for them instead, for this call only.
+	ApplyNamed(interface{}, map[string]interface{}) error
 }
 
+// FieldInterceptor receives every field Apply injects together with the
+// value about to be set, and returns the value to set instead. Returning the
+// value unchanged leaves the field as Apply would have set it.
+type FieldInterceptor func(field reflect.StructField, value reflect.Value) reflect.Value
+
 // Invoker represents an interface for calling functions via reflection.
 type Invoker interface {
 	// Invoke attempts to call the interface{} provided as a function,
@@ -45,92 +138,470 @@ type Invoker interface {
 	// a slice of reflect.Value representing the returned values of the function.
 	// Returns an error if the injection fails.
 	Invoke(interface{}) ([]reflect.Value, error)
+	// InvokeCtx works like Invoke, but context.Context arguments receive the
+	// context provided and values pushed onto it with PushScope take precedence
+	// over the Type map.
+	InvokeCtx(context.Context, interface{}) ([]reflect.Value, error)
+	// InvokeErr works like Invoke, but if the last return value of the
+	// function is an error it is split off and returned when it is not nil.
+	InvokeErr(interface{}) ([]reflect.Value, error)
+	// GetCtx resolves the given Type like an argument of InvokeCtx.
+	GetCtx(context.Context, reflect.Type) reflect.Value
+	// InvokeLenient works like Invoke, but the parameters at the given indices
+	// are passed as zero values instead of being resolved.
+	InvokeLenient(interface{}, []int) ([]reflect.Value, error)
+	// InvokeHandler calls the command handler registered with
+	// MapCommandHandlers for the type of the command provided, passing it the
+	// command and injecting its other arguments.
+	InvokeHandler(cmd interface{}) ([]reflect.Value, error)
+	// CanInvoke reports, without calling the function provided or invoking
+	// any provider, whether Invoke could resolve all of its arguments.
+	CanInvoke(interface{}) error
 }
 
 // TypeMapper represents an interface for mapping interface{} values based on type.
 type TypeMapper interface {
 	// Maps the interface{} value based on its immediate type from reflect.TypeOf.
 	Map(interface{}) TypeMapper
+	// Maps the interface{} value like Map and marks its type as shared: child
+	// injectors can resolve it but mapping the same type in a child panics.
+	MapShared(interface{}) TypeMapper
+	// Maps the interface{} value based on its immediate type from reflect.TypeOf,
+	// storing the value returned by the transform func instead of the original.
+	// The transform runs once when the value is mapped, not on every Get.
+	MapWith(interface{}, func(reflect.Value) reflect.Value) TypeMapper
 	// Maps the interface{} value based on the pointer of an Interface provided.
 	// This is really only useful for mapping a value as an interface, as interfaces
 	// cannot at this time be referenced directly without a pointer.
 	MapTo(interface{}, interface{}) TypeMapper
+	// Maps the interface{} value like MapTo, but under a name, so several
+	// implementations of one Interface can be told apart by the name in the
+	// inject tag of a field.
+	MapToName(string, interface{}, interface{}) TypeMapper
+	// Maps the interface{} value based on its immediate type like Map, but
+	// under a name, so several values of one type can be told apart.
+	MapNamed(string, interface{}) TypeMapper
+	// Registers a func that combines every mapped implementor of the Interface
+	// provided into a single value. Get on the Interface then returns the combined
+	// value instead of picking one implementor, e.g. io.MultiWriter for io.Writer.
+	MapCombiner(interface{}, func([]reflect.Value) reflect.Value) TypeMapper
+	// Maps a provider func to the type of its first return value. The provider
+	// is invoked with its arguments injected the first time the type is
+	// requested and the returned value is cached. It may return an error as
+	// its second return value.
+	MapProvider(interface{}, ...ProviderOption) TypeMapper
+	// Registers each constructor as the provider of all its return values but
+	// a trailing error, so requesting any of them constructs the transitive
+	// dependencies it needs from the other constructors.
+	AutoWire(...interface{}) TypeMapper
+	// Registers the Handle methods of a service as the handlers of the command
+	// types they take as their first parameter, see Invoker.InvokeHandler.
+	MapCommandHandlers(interface{}) TypeMapper
+	// Registers a factory taking runtime arguments, resolved as a func taking
+	// its leading arguments from the caller and injecting the others.
+	MapParamProvider(interface{}) TypeMapper
+	// Discards the value constructed by the provider of the Type provided, so
+	// the next Get constructs it again.
+	Refresh(reflect.Type)
+	// Maps every plugin added to the global registry with Register.
+	LoadPlugins()
+	// Registers a func computing the Value of the given Type from the context
+	// of InvokeCtx and GetCtx.
+	MapResolver(reflect.Type, func(context.Context) reflect.Value) TypeMapper
+	// Registers a func of exactly one argument and one result as the adapter
+	// producing its result from the binding of its argument on demand.
+	MapAdapter(interface{}) TypeMapper
+	// Registers a func supplying a default Value for the given Type, used by
+	// Invoke and Apply only when the Type cannot be resolved otherwise.
+	MapDefaultSupplier(reflect.Type, func() reflect.Value) TypeMapper
 	// Provides a possibility to directly insert a mapping based on type and value.
 	// This makes it possible to directly map type arguments not possible to instantiate
 	// with reflect like unidirectional channels.
 	Set(reflect.Type, reflect.Value) TypeMapper
+	// Maps the given reflect.Type to the given reflect.Value like Set, also
+	// when the injector was created WithNoOverride and the Type is mapped.
+	Override(reflect.Type, reflect.Value) TypeMapper
 	// Returns the Value that is mapped to the current type. Returns a zeroed Value if
 	// the Type has not been mapped.
 	Get(reflect.Type) reflect.Value
+	// Returns the Value mapped to the Type like Get, the number of levels up the
+	// parent chain it was found at, 0 being this injector, and whether it was
+	// found.
+	GetNearest(reflect.Type) (reflect.Value, int, bool)
+	// Returns the Value registered under the name with MapNamed or MapToName
+	// for the given Type, in this injector or its parents, or a zeroed Value.
+	GetNamed(string, reflect.Type) reflect.Value
+	// Returns every mapped or provided Value whose type is, or implements, the
+	// given Type, in this injector and its parents. Values implementing Ordered
+	// are sorted by Order(), all others keep their registration order.
+	GetAll(reflect.Type) []reflect.Value
+	// Adds the interface{} value to the named group, whose members are
+	// resolved together and may share a type.
+	MapGroup(string, interface{}) TypeMapper
+	// Returns the members of the named group, which must be assignable to
+	// the given Type, in this injector and its parents.
+	GetGroup(string, reflect.Type) ([]reflect.Value, error)
+	// Returns the Value mapped to exactly the given Type in this injector and
+	// whether there is one. Unlike Get it never scans for implementors, asks
+	// the parent or invokes a provider.
+	Peek(reflect.Type) (reflect.Value, bool)
+	// Reports whether Invoke could resolve the given Type with the current
+	// bindings, without invoking any provider.
+	Has(reflect.Type) bool
+	// Removes the Value mapped to exactly the given Type in this injector and
+	// reports whether there was one.
+	Unmap(reflect.Type) bool
+	// Returns the Types mapped in this injector, in registration order.
+	Keys() []reflect.Type
+	// Returns the number of Types mapped in this injector.
+	Len() int
+	// Returns the first Value of the given Type, or implementing it, for which
+	// the predicate returns true, and whether there is one.
+	GetWhere(reflect.Type, func(reflect.Value) bool) (reflect.Value, bool)
+	// Maps the interface{} value under an arbitrary comparable key instead of
+	// a type. Keyed values live apart from the Type map.
+	MapKeyed(key interface{}, val interface{}) TypeMapper
+	// Returns the Value mapped under the key and whether there is one,
+	// checking the parent if the key is not mapped in this injector.
+	GetKeyed(key interface{}) (reflect.Value, bool)
+}
+
+// Ordered can be implemented by mapped values to control their position in
+// the results of GetAll, and which implementor of an interface is preferred
+// WithStrictImplementors. Lower orders come first; values that do not
+// implement Ordered are treated as having order 0.
+type Ordered interface {
+	Order() int
 }
 
 type Event struct {
 	Src  Injector
 	Type string
 	Data interface{}
+
+	// respond receives the reply of the handlers to an event sent by Request.
+	respond chan<- response
+	// ctx is the context the event was fired with by FireCtx.
+	ctx context.Context
 }
 
 type Handler interface{}
 
+var eventType = reflect.TypeOf(Event{})
+
 func validateHandler(handler Handler) {
 	t := reflect.TypeOf(handler)
 	if t.Kind() != reflect.Func {
 		panic("inject handler must be a callable func")
 	}
-	if t.NumIn() == 0 && t.In(0) != Event.Type {
+	in := 0
+	if t.NumIn() > 1 && t.In(0) == contextType {
+		in = 1
+	}
+	if t.NumIn() == in || t.In(in) != eventType {
 		panic("the first arg of inject handler must be a Event type")
 	}
 }
 
+// checkHandler validates handler like validateHandler and also panics if one
+// of the arguments following the Event is neither an Event nor a
+// context.Context and cannot be resolved by i, see Has. Resolvability is
+// checked against the bindings of i at the time the handler is registered,
+// so dependencies have to be mapped before their handlers are.
+func (i *injector) checkHandler(key string, handler Handler) {
+	validateHandler(handler)
+	t := reflect.TypeOf(handler)
+	for n := 0; n < t.NumIn(); n++ {
+		arg := t.In(n)
+		if arg != eventType && arg != contextType && !i.Has(arg) {
+			panic(fmt.Sprintf("inject handler for %q takes %v, which cannot be resolved", key, arg))
+		}
+	}
+}
+
 type injector struct {
-	values   map[reflect.Type]reflect.Value
-	handlers map[string][]Handler
-	events   chan Event
-	stopped  chan bool
-	parent   Injector
+	values    map[reflect.Type]reflect.Value
+	order     []reflect.Type
+	combiners map[reflect.Type]func([]reflect.Value) reflect.Value
+	providers map[reflect.Type]*provider
+	provided  []reflect.Type
+	shared    map[reflect.Type]bool
+	payloads  map[string]reflect.Type
+	defaults  map[reflect.Type]func() reflect.Value
+	keyed     map[interface{}]reflect.Value
+	named     map[string]map[reflect.Type]reflect.Value
+	resolvers map[reflect.Type]func(context.Context) reflect.Value
+	adapters  map[reflect.Type]reflect.Value
+	factories []reflect.Value
+	commands  map[reflect.Type]reflect.Value
+	groups    map[string][]*groupMember
+	// results holds the result providers with fields registered under a
+	// name, see Out, along with the types of those fields.
+	results   map[string][]namedResult
+	handlers  map[string][]Handler
+	// states and watchers are the state values set with SetState and
+	// the handlers registered with OnState. stateMu serializes their updates
+	// so that every handler receives every value once, in order.
+	states    map[string]interface{}
+	watchers  map[string][]Handler
+	stateMu   sync.Mutex
+	events    chan Event
+	stopped   chan bool
+	loopDone  chan struct{}
+	up        atomic.Pointer[parentLink]
+	mu        sync.RWMutex
+	onMiss    func(reflect.Type)
+	onPanic   func(Event, interface{})
+	cleanups  []func() error
+	started   []reflect.Value
+	hooksOn   bool
+	intercept []FieldInterceptor
+
+	// options
+	strict         bool
+	lastWins       bool
+	resolveLog     func(format string, args ...interface{})
+	requestTimeout time.Duration
+	provideHooks   []ProviderInterceptor
+	funcGuard      bool
+	funcWarn       func(format string, args ...interface{})
+	freeze         bool
+	mutations      bool
+	dumpValues     bool
+	noOverride     bool
+
+	// frozen is set once the handlers are frozen, see WithFrozenHandlers.
+	frozen atomic.Bool
+	// changes holds the changes reported WithMutationEvents.
+	changes mutationQueue
 	/*injectors     []*injector
 	injectorsLock sync.RWMutex*/
 }
 
 // InterfaceOf dereferences a pointer to an Interface type.
-// It panics if value is not an pointer to an interface.
+// It panics if value is not an pointer to an interface. The panic message
+// names what was passed instead, telling apart nil, a value that is not a
+// pointer at all and a pointer to a concrete type.
 func InterfaceOf(value interface{}) reflect.Type {
 	t := reflect.TypeOf(value)
 
-	for t.Kind() == reflect.Ptr {
-		t = t.Elem()
+	if t == nil {
+		panic(interfaceOfError("nil", "an untyped nil"))
 	}
 
-	if t.Kind() != reflect.Interface {
-		panic("Called inject.InterfaceOf with a value that is not a pointer to an interface. (*MyInterface)(nil)")
+	if t.Kind() != reflect.Ptr {
+		panic(interfaceOfError(t.String(), "not a pointer"))
 	}
 
-	return t
+	e := t
+	for e.Kind() == reflect.Ptr {
+		e = e.Elem()
+	}
+
+	if e.Kind() != reflect.Interface {
+		panic(interfaceOfError(t.String(), "a pointer to the concrete type "+e.String()))
+	}
+
+	return e
+}
+
+func interfaceOfError(got, reason string) string {
+	return fmt.Sprintf("Called inject.InterfaceOf with a value that is not a pointer to an interface: expected (*MyInterface)(nil), got %s (%s)", got, reason)
+}
+
+// Option configures an Injector created by New.
+type Option func(*injector)
+
+// WithStrictImplementors makes resolving an interface through its
+// implementors fail when more than one mapped type of the same injector
+// implements it, instead of using the first one registered. The error names
+// every candidate. Implementors can be given a priority by implementing
+// Ordered: the one with the lowest order is then used, and resolving only
+// fails if several share the lowest order, those not implementing Ordered
+// having order 0. It has no effect on interfaces mapped explicitly with
+// MapTo or combined with MapCombiner.
+func WithStrictImplementors() Option {
+	return func(i *injector) {
+		i.strict = true
+	}
+}
+
+// WithNoOverride makes mapping a type already mapped in the injector, with
+// Map, MapTo, Set and the like, panic instead of silently replacing the
+// first value, to catch accidental shadowing in large setups. Values are
+// then replaced explicitly with Override. Mapping a type a parent maps
+// still shadows it, and Unmap followed by a new mapping is allowed.
+func WithNoOverride() Option {
+	return func(i *injector) {
+		i.noOverride = true
+	}
+}
+
+// WithLastImplementorWins makes resolving an interface through its
+// implementors pick the most recently registered one instead of the first,
+// so modules mapped later override the defaults of earlier ones. In this
+// mode mapping a type again also moves it to the end of the registration
+// order.
+func WithLastImplementorWins() Option {
+	return func(i *injector) {
+		i.lastWins = true
+	}
+}
+
+// WithResolveLogger makes Invoke and Apply log, through logf, the steps
+// attempted to resolve a dependency they failed to resolve: each type
+// requested, the level of the injector hierarchy searched (0 being the
+// injector itself) and the providers entered on the way. The steps are only
+// worked out after a failure, so successful resolutions cost nothing.
+func WithResolveLogger(logf func(format string, args ...interface{})) Option {
+	return func(i *injector) {
+		i.resolveLog = logf
+	}
+}
+
+// WithFuncGuard makes Map and MapShared reject funcs, which are usually a
+// constructor passed by mistake, e.g. Map(NewService) instead of
+// Map(NewService()), and would otherwise be mapped as a func type that
+// nothing requests. If warnf is nil the mapping panics, otherwise warnf is
+// told about it and the func is mapped anyway. Funcs can still be mapped
+// without warning with Set or MapTo.
+func WithFuncGuard(warnf func(format string, args ...interface{})) Option {
+	return func(i *injector) {
+		i.funcGuard = true
+		i.funcWarn = warnf
+	}
 }
 
 // New returns a new Injector.
-func New() Injector {
-	return &injector{
+func New(opts ...Option) Injector {
+	inj := &injector{
 		values: make(map[reflect.Type]reflect.Value),
+		combiners: make(map[reflect.Type]func([]reflect.Value) reflect.Value),
+		providers: make(map[reflect.Type]*provider),
+		shared: make(map[reflect.Type]bool),
+		payloads: make(map[string]reflect.Type),
+		defaults: make(map[reflect.Type]func() reflect.Value),
+		keyed: make(map[interface{}]reflect.Value),
+		named: make(map[string]map[reflect.Type]reflect.Value),
+		resolvers: make(map[reflect.Type]func(context.Context) reflect.Value),
+		adapters: make(map[reflect.Type]reflect.Value),
+		commands: make(map[reflect.Type]reflect.Value),
+		groups: make(map[string][]*groupMember),
+		results: make(map[string][]namedResult),
 		handlers: make(map[string][]Handler),
+		states: make(map[string]interface{}),
+		watchers: make(map[string][]Handler),
 		events: make(chan Event),
 		stopped: make(chan bool),
+		requestTimeout: DefaultRequestTimeout,
 		/*injectors: make([]*injector,0),*/
 	}
+	for _, opt := range opts {
+		opt(inj)
+	}
+	return inj
+}
+
+// Must returns a new Injector, created with opts, after wiring it with
+// setup. It panics if setup returns an error, which makes it suitable for
+// bootstrapping in main where a wiring failure should abort the program.
+func Must(setup func(Injector) error, opts ...Option) Injector {
+	inj := New(opts...)
+	if err := setup(inj); err != nil {
+		panic(fmt.Sprintf("inject: wiring the injector failed: %v", err))
+	}
+	return inj
+}
+
+// NewFromMap returns a new Injector, created with opts, with each value of
+// m mapped as the type it is keyed by, as Set does. Unlike Map, which maps a
+// value as its concrete type, a key may be an interface the value
+// implements, so m can describe the bindings of a whole configuration. A nil
+// value maps the zero value of its type. NewFromMap returns an error, and no
+// Injector, if a value is not assignable to its type.
+func NewFromMap(m map[reflect.Type]interface{}, opts ...Option) (Injector, error) {
+	types := make([]reflect.Type, 0, len(m))
+	for t := range m {
+		types = append(types, t)
+	}
+	// sorted so the bindings and a failure do not depend on the map order
+	sort.Slice(types, func(a, b int) bool { return types[a].String() < types[b].String() })
+
+	inj := New(opts...)
+	for _, t := range types {
+		v := reflect.ValueOf(m[t])
+		switch {
+		case !v.IsValid():
+			v = reflect.Zero(t)
+		case !v.Type().AssignableTo(t):
+			return nil, fmt.Errorf("Value of type %v is not assignable to %v", v.Type(), t)
+		}
+		inj.Set(t, v)
+	}
+	return inj, nil
 }
 
 // Invoke attempts to call the interface{} provided as a function,
 // providing dependencies for function arguments based on Type.
 // Returns a slice of reflect.Value representing the returned values of the function.
+// The variadic parameter of a variadic function receives the slice mapped
+// for its type, if any, and is left empty otherwise.
 // Returns an error if the injection fails.
 // It panics if f is not a function
 func (inj *injector) Invoke(f interface{}) ([]reflect.Value, error) {
+	return inj.invokeLogged(f, nil)
+}
+
+// invokeLogged calls f like Invoke, leaving the parameters set in skip
+// to their zero value, and logs a failure to the resolve logger, if any.
+func (inj *injector) invokeLogged(f interface{}, skip []bool) ([]reflect.Value, error) {
+	out, err := inj.invokeSkipping(f, inj.resolveArg, skip)
+	if err != nil && inj.resolveLog != nil {
+		var types []reflect.Type
+		for n, t := range argTypes(reflect.TypeOf(f)) {
+			if n >= len(skip) || !skip[n] {
+				types = append(types, t)
+			}
+		}
+		inj.logFailure(types, err)
+	}
+	return out, err
+}
+
+// argTypes returns the parameter types of the func type t.
+func argTypes(t reflect.Type) []reflect.Type {
+	types := make([]reflect.Type, t.NumIn())
+	for i := range types {
+		types[i] = t.In(i)
+	}
+	return types
+}
+
+// invoke calls f with the arguments returned by resolve for each parameter.
+func (inj *injector) invoke(f interface{}, resolve func(reflect.Type) (reflect.Value, error)) ([]reflect.Value, error) {
+	return inj.invokeSkipping(f, resolve, nil)
+}
+
+// invokeSkipping calls f like invoke, except that each parameter whose
+// index is set in skip receives the zero value of its type unresolved.
+func (inj *injector) invokeSkipping(f interface{}, resolve func(reflect.Type) (reflect.Value, error), skip []bool) ([]reflect.Value, error) {
 	t := reflect.TypeOf(f)
 
 	var in = make([]reflect.Value, t.NumIn()) //Panic if t is not kind of Func
-	for i := 0; i < t.NumIn(); i++ {
+	for i := range in {
 		argType := t.In(i)
-		val := inj.Get(argType)
+		if i < len(skip) && skip[i] {
+			in[i] = reflect.Zero(argType)
+			continue
+		}
+		val, err := resolve(argType)
+		if err != nil {
+			return nil, err
+		}
+		if !val.IsValid() && t.IsVariadic() && i == len(in)-1 {
+			// the variadic part is left empty unless its slice is mapped
+			val = reflect.MakeSlice(argType, 0, 0)
+		}
 		if !val.IsValid() {
 			return nil, fmt.Errorf("Value not found for type %v", argType)
 		}
@@ -138,13 +609,359 @@ func (inj *injector) Invoke(f interface{}) ([]reflect.Value, error) {
 		in[i] = val
 	}
 
+	return call(f, in)
+}
+
+// FastInvoker is implemented by func types that can call themselves without
+// reflection, e.g.
+//
+//	type Handler func(http.ResponseWriter, *http.Request)
+//
+//	func (h Handler) Invoke(args []interface{}) ([]reflect.Value, error) {
+//		h(args[0].(http.ResponseWriter), args[1].(*http.Request))
+//		return nil, nil
+//	}
+//
+// Invoke, and everything invoking funcs with injected arguments, still
+// resolves the arguments by the parameter types of the func, but then calls
+// its Invoke method with them instead of going through reflect.Value.Call,
+// which is much cheaper on hot paths like routing. The variadic part, if
+// any, is passed as a single slice.
+type FastInvoker interface {
+	Invoke([]interface{}) ([]reflect.Value, error)
+}
+
+// call calls the func f with in, through its Invoke method if it is a
+// FastInvoker, passing the last of in as the variadic slice if f is
+// variadic.
+func call(f interface{}, in []reflect.Value) ([]reflect.Value, error) {
+	if fast, ok := f.(FastInvoker); ok {
+		args := make([]interface{}, len(in))
+		for n, v := range in {
+			args[n] = v.Interface()
+		}
+		return fast.Invoke(args)
+	}
+	if reflect.TypeOf(f).IsVariadic() {
+		return reflect.ValueOf(f).CallSlice(in), nil
+	}
 	return reflect.ValueOf(f).Call(in), nil
 }
 
+// CanInvoke checks that every parameter of f can be resolved with the
+// current bindings, as Has does, without calling f or constructing anything.
+// It returns nil if Invoke could resolve them all, and otherwise an error
+// listing each parameter that cannot be resolved by index and type.
+func (inj *injector) CanInvoke(f interface{}) error {
+	t := reflect.TypeOf(f)
+	if t == nil || t.Kind() != reflect.Func {
+		return fmt.Errorf("CanInvoke expects a func, got %v", t)
+	}
+
+	var missing []string
+	for n := 0; n < t.NumIn(); n++ {
+		if !inj.Has(t.In(n)) && !(t.IsVariadic() && n == t.NumIn()-1) {
+			missing = append(missing, fmt.Sprintf("%d (%v)", n, t.In(n)))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("Cannot invoke %v, no value for parameters %s", t, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// InvokeLenient calls f like Invoke, except that each parameter whose index
+// is listed in skip receives the zero value of its type instead of being
+// resolved, which allows calling functions with reserved or otherwise
+// unresolvable parameters. The other parameters are resolved, logged and
+// traced exactly as by Invoke. It returns an error if an index is out of
+// range.
+func (inj *injector) InvokeLenient(f interface{}, skip []int) ([]reflect.Value, error) {
+	t := reflect.TypeOf(f)
+
+	skipped := make([]bool, t.NumIn())
+	for _, n := range skip {
+		if n < 0 || n >= len(skipped) {
+			return nil, fmt.Errorf("InvokeLenient: parameter index %d out of range for %v", n, t)
+		}
+		skipped[n] = true
+	}
+
+	return inj.invokeLogged(f, skipped)
+}
+
+// InvokeErr invokes f like Invoke and, if the last return value of f is of
+// type error, returns the other return values and that error, so callers do
+// not have to dig it out of the returned values. The error is nil only if
+// both the injection and f succeeded.
+func (inj *injector) InvokeErr(f interface{}) ([]reflect.Value, error) {
+	out, err := inj.Invoke(f)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(out) - 1; n >= 0 && reflect.TypeOf(f).Out(n) == errorType {
+		err, _ = out[n].Interface().(error)
+		out = out[:n]
+	}
+	return out, err
+}
+
+// InvokeAndMap invokes f like Invoke and maps each of its return values,
+// except those of type error, under its declared return type, so a single
+// constructor can populate several bindings. If f returns a non-nil error it
+// is returned and nothing is mapped. Type collisions are errors reported
+// before f is called: two return values of the same type, or a return type
+// that is already mapped in this injector.
+func (inj *injector) InvokeAndMap(f interface{}) error {
+	t := reflect.TypeOf(f)
+	if t == nil || t.Kind() != reflect.Func {
+		return fmt.Errorf("InvokeAndMap expects a func, got %v", t)
+	}
+
+	seen := make(map[reflect.Type]bool)
+	for n := 0; n < t.NumOut(); n++ {
+		out := t.Out(n)
+		if out == errorType {
+			continue
+		}
+		if seen[out] {
+			return fmt.Errorf("InvokeAndMap: %v returns type %v more than once", t, out)
+		}
+		inj.mu.RLock()
+		_, ok := inj.values[out]
+		inj.mu.RUnlock()
+		if ok {
+			return fmt.Errorf("InvokeAndMap: type %v is already mapped", out)
+		}
+		seen[out] = true
+	}
+
+	vals, err := inj.Invoke(f)
+	if err != nil {
+		return err
+	}
+
+	for n, v := range vals {
+		if t.Out(n) == errorType {
+			if err, _ := v.Interface().(error); err != nil {
+				return err
+			}
+		}
+	}
+
+	for n, v := range vals {
+		if out := t.Out(n); out != errorType {
+			inj.Set(out, v)
+		}
+	}
+
+	return nil
+}
+
+var injectorType = reflect.TypeOf((*Injector)(nil)).Elem()
+
+// InterceptFields adds intercept to the field interceptors consulted by
+// Apply. Interceptors chain: each receives the value returned by the
+// previous one, those of parents running before those of their children
+// and, within an injector, in registration order. Apply fails for a field if
+// the value returned by the last interceptor is not assignable to it.
+func (i *injector) InterceptFields(intercept FieldInterceptor) {
+	i.mu.Lock()
+	i.intercept = append(i.intercept, intercept)
+	i.mu.Unlock()
+}
+
+// interceptors returns the field interceptors of i and its parents, those
+// of the root first.
+func (i *injector) interceptors() []FieldInterceptor {
+	var chain []FieldInterceptor
+	if p, ok := i.parent().(*injector); ok {
+		chain = p.interceptors()
+	}
+
+	i.mu.RLock()
+	chain = append(chain, i.intercept...)
+	i.mu.RUnlock()
+	return chain
+}
+
+// isLazyCollection reports whether t is of the form func() []I, where I is
+// an interface.
+func isLazyCollection(t reflect.Type) bool {
+	return t.Kind() == reflect.Func && t.NumIn() == 0 && t.NumOut() == 1 &&
+		t.Out(0).Kind() == reflect.Slice && t.Out(0).Elem().Kind() == reflect.Interface
+}
+
+// lazyCollection returns a func of type t collecting the implementors of
+// the element type of its result with GetAll on each call.
+func (inj *injector) lazyCollection(t reflect.Type) reflect.Value {
+	sliceType := t.Out(0)
+	return reflect.MakeFunc(t, func([]reflect.Value) []reflect.Value {
+		return []reflect.Value{makeSlice(sliceType, inj.GetAll(sliceType.Elem()))}
+	})
+}
+
+// collection returns a slice of type t holding the result of GetAll for its
+// element type, if t is a slice of interfaces. The slice is empty, but not
+// nil, if GetAll finds no value.
+func (i *injector) collection(t reflect.Type, path *construction) reflect.Value {
+	if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Interface {
+		return reflect.Value{}
+	}
+	return makeSlice(t, i.getAll(t.Elem(), path))
+}
+
+// makeSlice returns a slice of type t holding vals.
+func makeSlice(t reflect.Type, vals []reflect.Value) reflect.Value {
+	s := reflect.MakeSlice(t, len(vals), len(vals))
+	for n, v := range vals {
+		s.Index(n).Set(v)
+	}
+	return s
+}
+
+// child returns a new injector created with the options of i, having i as
+// its parent.
+func (i *injector) child() *injector {
+	c := New().(*injector)
+	c.strict = i.strict
+	c.lastWins = i.lastWins
+	c.resolveLog = i.resolveLog
+	c.requestTimeout = i.requestTimeout
+	c.provideHooks = i.provideHooks
+	c.funcGuard = i.funcGuard
+	c.freeze = i.freeze
+	c.mutations = i.mutations
+	c.dumpValues = i.dumpValues
+	c.noOverride = i.noOverride
+	c.funcWarn = i.funcWarn
+	c.SetParent(i)
+	return c
+}
+
+// parseTag splits an inject tag into the name before the first comma and
+// the options following it. A tag of just "optional" is the "optional"
+// option without a name.
+func parseTag(tag string) (string, []string) {
+	parts := strings.Split(tag, ",")
+	if parts[0] == "optional" {
+		return "", parts
+	}
+	return parts[0], parts[1:]
+}
+
+func hasOption(opts []string, opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// withName returns the result of v.WithName(name) if v has such a method
+// returning a value assignable to t, and v otherwise.
+func withName(v reflect.Value, t reflect.Type, name string) reflect.Value {
+	m := v.MethodByName("WithName")
+	if !m.IsValid() {
+		return v
+	}
+	mt := m.Type()
+	if mt.NumIn() != 1 || mt.In(0).Kind() != reflect.String || mt.NumOut() != 1 || !mt.Out(0).AssignableTo(t) {
+		return v
+	}
+	return m.Call([]reflect.Value{reflect.ValueOf(name).Convert(mt.In(0))})[0]
+}
+
 // Maps dependencies in the Type map to each field in the struct
 // that is tagged with 'inject'.
-// Returns an error if the injection fails.
+// If the tag names a binding registered with MapToName, e.g. `inject:"file"`,
+// the field receives that binding instead of resolving by type.
+// With the "named" tag option, e.g. `inject:",named"`, a dependency having a
+// WithName(string) method returning a value assignable to the field gets the
+// result of calling WithName with the name of the struct type injected
+// instead, so loggers and tracers are scoped to the component they are
+// injected into.
+// A tagged field of type func() []I, where I is an interface, that is not
+// mapped itself receives a func returning the result of GetAll for I each
+// time it is called, so it sees values mapped, and providers registered,
+// after Apply. The func may be called from any goroutine.
+// A tagged field of type Injector receives, unless something is mapped to
+// Injector, a new child of the injector created with the same options, which
+// gives the struct its own sub-scope. The child belongs to the struct: it is
+// never closed by its parent, so the struct is responsible for calling Close
+// on it when it is done with it.
+// A tag listing candidate types, e.g. `inject:"types=*RedisCache,*MemCache"`,
+// makes the field receive the first of them, in priority order, that can be
+// resolved, which allows falling back from a preferred implementation to
+// another. Candidates are type names, with or without their package, and
+// each of them must be assignable to the field.
+// A tag with a dotted config path, e.g. `inject:"config:server.port"`, makes
+// the field receive the value at that path in the map[string]interface{}
+// mapped in the injector, such as configuration decoded from JSON, converted
+// to the type of the field. Without such a mapping no path can be found.
+// A slice field tagged with a group, e.g. `inject:"group:routes"`, receives
+// the members of that group, see GetGroup.
+// With the "optional" tag option, e.g. `inject:",optional"` or just
+// `inject:"optional"`, a field whose dependency cannot be found is left as it
+// is instead of failing.
+// With the "nested" tag option, e.g. `inject:",nested"`, a field holding a
+// struct, or a pointer to one, is not resolved itself: Apply injects the
+// fields of that struct instead, allocating it if the pointer is nil, and so
+// on for its own nested fields.
+// Apply does not stop at the first field it cannot inject: it injects every
+// field it can and returns the errors of the others joined with
+// errors.Join, one line per field, which errors.Is and errors.As see
+// through, or nil if every field was injected.
 func (inj *injector) Apply(val interface{}) error {
+	return errors.Join(inj.apply(val, true, nil)...)
+}
+
+// ApplyAll injects every tagged field it can, like Apply, and returns the
+// error of each field it could not inject, in field order, instead of
+// joining them. Every error names its field, so fields of the same type can
+// be told apart, and wraps the cause, e.g. the error of a failed provider.
+func (inj *injector) ApplyAll(val interface{}) []error {
+	return inj.apply(val, true, nil)
+}
+
+// ApplyNamed injects val like Apply, except that each field named in names,
+// tagged or not, is set to the value given for it instead of being
+// resolved, which lets fields of the same type, e.g. two *sql.DB, receive
+// distinct values without registering named bindings on the injector. These
+// values take precedence over everything else, including the tag of the
+// field, and are set as given, without going through the interceptors.
+// It returns an error if val has no settable field of a name given, or
+// if a value is not assignable to its field.
+func (inj *injector) ApplyNamed(val interface{}, names map[string]interface{}) error {
+	v := reflect.ValueOf(val)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	for name, nv := range names {
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("ApplyNamed: %v has no field %s", v.Type(), name)
+		}
+		f := v.FieldByName(name)
+		if !f.IsValid() || !f.CanSet() {
+			return fmt.Errorf("ApplyNamed: %v has no field %s", v.Type(), name)
+		}
+		if t := reflect.TypeOf(nv); t == nil || !t.AssignableTo(f.Type()) {
+			return fmt.Errorf("Field %s: value of type %v is not assignable to %v", name, t, f.Type())
+		}
+	}
+
+	if errs := inj.apply(val, false, names); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// apply injects the tagged fields of val, and sets the fields named in
+// names to their value. Unless keepGoing is set it stops at the first
+// failure.
+func (inj *injector) apply(val interface{}, keepGoing bool, names map[string]interface{}) []error {
+	var errs []error
 	v := reflect.ValueOf(val)
 
 	for v.Kind() == reflect.Ptr {
@@ -157,14 +974,77 @@ func (inj *injector) Apply(val interface{}) error {
 
 	t := v.Type()
 
-	for i := 0; i < v.NumField(); i++ {
+	for i, plan := range planFields(t) {
 		f := v.Field(i)
-		structField := t.Field(i)
-		if f.CanSet() && (structField.Tag == "inject" || structField.Tag.Get("inject") != "") {
+		structField := plan.field
+		if nv, ok := names[structField.Name]; ok && f.CanSet() {
+			f.Set(reflect.ValueOf(nv))
+			continue
+		}
+		if f.CanSet() && plan.tagged {
 			ft := f.Type()
-			v := inj.Get(ft)
-			if !v.IsValid() {
-				return fmt.Errorf("Value not found for type %v", ft)
+			var v reflect.Value
+			var err error
+			name, opts, candidates := plan.name, plan.opts, plan.candidates
+			path, isConfig, group := plan.path, plan.isConfig, plan.group
+			if plan.nested {
+				nerrs := inj.applyNested(f, keepGoing)
+				for _, err := range nerrs {
+					errs = append(errs, fmt.Errorf("Field %s: %w", structField.Name, err))
+				}
+				if len(nerrs) > 0 && !keepGoing {
+					return errs
+				}
+				continue
+			}
+			if candidates != nil {
+				v, err = inj.getCandidate(ft, candidates)
+			} else if isConfig {
+				v, err = inj.getConfig(ft, path)
+			} else if group != "" {
+				v, err = inj.getGroupSlice(ft, group, nil)
+			} else if nv, ok, nerr := inj.getNamed(name, ft); ok || nerr != nil {
+				v, err = nv, nerr
+			} else if ft == injectorType {
+				if v, err = inj.lookup(ft, nil); !v.IsValid() && err == nil {
+					v = reflect.ValueOf(inj.child())
+				}
+			} else {
+				v, err = inj.resolveArg(ft)
+			}
+			if err != nil {
+				err = fmt.Errorf("Field %s: %w", structField.Name, err)
+			} else if !v.IsValid() && hasOption(opts, "optional") {
+				continue
+			} else if !v.IsValid() && candidates != nil {
+				err = fmt.Errorf("Field %s: none of the types %s found", structField.Name, strings.Join(candidates, ", "))
+			} else if !v.IsValid() && isConfig {
+				err = fmt.Errorf("Field %s: config path %q not found", structField.Name, path)
+			} else if !v.IsValid() {
+				err = fmt.Errorf("Value not found for type %v", ft)
+				if keepGoing {
+					err = fmt.Errorf("Field %s: %w", structField.Name, err)
+				}
+			} else {
+				if hasOption(opts, "named") {
+					v = withName(v, ft, t.Name())
+				}
+				for _, intercept := range inj.interceptors() {
+					v = intercept(structField, v)
+				}
+				if !v.IsValid() || !v.Type().AssignableTo(ft) {
+					err = fmt.Errorf("Field %s: interceptor returned %v, which is not assignable to %v", structField.Name, typeOf(v), ft)
+				}
+			}
+			if err != nil {
+				if inj.resolveLog != nil {
+					inj.logFailure([]reflect.Type{ft}, err)
+				}
+				errs = append(errs, err)
+				if !keepGoing {
+					return errs
+				}
+				continue
 			}
 
 			f.Set(v)
@@ -172,72 +1052,940 @@ func (inj *injector) Apply(val interface{}) error {
 
 	}
 
-	return nil
+	return errs
+}
+
+// fieldPlan is what apply needs to know about a field of a struct type,
+// computed once per type.
+type fieldPlan struct {
+	field      reflect.StructField
+	tagged     bool
+	nested     bool
+	name       string
+	opts       []string
+	candidates []string
+	path       string
+	isConfig   bool
+	group      string
+}
+
+// fieldPlans caches the plans of the struct types passed to apply, keyed by
+// their reflect.Type, so their tags are parsed once.
+var fieldPlans sync.Map
+
+// planFields returns the plan of each field of the struct type t.
+func planFields(t reflect.Type) []fieldPlan {
+	if plans, ok := fieldPlans.Load(t); ok {
+		return plans.([]fieldPlan)
+	}
+
+	plans := make([]fieldPlan, t.NumField())
+	for n := range plans {
+		sf := t.Field(n)
+		p := fieldPlan{field: sf, tagged: sf.Tag == "inject" || sf.Tag.Get("inject") != ""}
+		if p.tagged {
+			name, opts := parseTag(sf.Tag.Get("inject"))
+			p.nested = hasOption(opts, "nested")
+			p.candidates, p.opts = candidateTypes(name, opts)
+			p.path, p.isConfig = configPath(name)
+			p.group, _ = groupName(name)
+			p.name = name
+		}
+		plans[n] = p
+	}
+	fieldPlans.Store(t, plans)
+	return plans
+}
+
+// applyNested injects the fields of the struct held by the field f tagged
+// with the "nested" option, allocating it if f is a nil pointer.
+func (inj *injector) applyNested(f reflect.Value, keepGoing bool) []error {
+	switch {
+	case f.Kind() == reflect.Struct:
+		return inj.apply(f.Addr().Interface(), keepGoing, nil)
+	case f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.Struct:
+		if f.IsNil() {
+			f.Set(reflect.New(f.Type().Elem()))
+		}
+		return inj.apply(f.Interface(), keepGoing, nil)
+	}
+	return []error{fmt.Errorf("nested value of type %v is not a struct", f.Type())}
 }
 
 // Maps the concrete value of val to its dynamic type using reflect.TypeOf,
 // It returns the TypeMapper registered in.
 func (i *injector) Map(val interface{}) TypeMapper {
-	i.values[reflect.TypeOf(val)] = reflect.ValueOf(val)
-	return i
+	i.guardFunc(val)
+	return i.Set(reflect.TypeOf(val), reflect.ValueOf(val))
+}
+
+// guardFunc reports val if it is a func and i was created WithFuncGuard.
+func (i *injector) guardFunc(val interface{}) {
+	t := reflect.TypeOf(val)
+	if !i.funcGuard || t == nil || t.Kind() != reflect.Func {
+		return
+	}
+	const format = "inject: Map given the func %v, use MapProvider to map the value it returns"
+	if i.funcWarn == nil {
+		panic(fmt.Sprintf(format, t))
+	}
+	i.funcWarn(format, t)
+}
+
+// Maps the value returned by transform(reflect.ValueOf(val)) to the dynamic
+// type of val. It panics if the transformed value is not assignable to
+// that type. It returns the TypeMapper registered in.
+func (i *injector) MapWith(val interface{}, transform func(reflect.Value) reflect.Value) TypeMapper {
+	t := reflect.TypeOf(val)
+	v := transform(reflect.ValueOf(val))
+	if !v.IsValid() || !v.Type().AssignableTo(t) {
+		panic(fmt.Sprintf("inject: MapWith transform returned %v, which is not assignable to %v", typeOf(v), t))
+	}
+	return i.Set(t, v)
+}
+
+// typeOf returns the type of v, or nil if v is the zero Value.
+func typeOf(v reflect.Value) reflect.Type {
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Type()
 }
 
 func (i *injector) MapTo(val interface{}, ifacePtr interface{}) TypeMapper {
-	i.values[InterfaceOf(ifacePtr)] = reflect.ValueOf(val)
+	return i.Set(InterfaceOf(ifacePtr), reflect.ValueOf(val))
+}
+
+// Registers combine for the interface ifacePtr points to. When the interface
+// itself has not been mapped, Get passes every mapped implementor, in
+// registration order, to combine and returns the result. The combined value
+// is built on each Get and is not cached.
+func (i *injector) MapCombiner(ifacePtr interface{}, combine func([]reflect.Value) reflect.Value) TypeMapper {
+	t := InterfaceOf(ifacePtr)
+	i.mu.Lock()
+	i.combiners[t] = combine
+	i.mu.Unlock()
 	return i
 }
 
+// Maps the concrete value of val to its dynamic type like Map and marks the
+// type as shared. Children of this injector, direct or not, resolve the
+// shared value through the parent chain but cannot shadow it: mapping the
+// same type in a child panics, as does setting a parent that shares a type
+// the child has already mapped. The injector owning the binding may still
+// replace it.
+func (i *injector) MapShared(val interface{}) TypeMapper {
+	i.Map(val)
+	i.mu.Lock()
+	i.shared[reflect.TypeOf(val)] = true
+	i.mu.Unlock()
+	return i
+}
+
+// checkShared panics if typ is shared by one of the parents of i.
+func (i *injector) checkShared(typ reflect.Type) {
+	for p, ok := i.parent().(*injector); ok; p, ok = p.parent().(*injector) {
+		p.mu.RLock()
+		shared := p.shared[typ]
+		p.mu.RUnlock()
+		if shared {
+			panic(fmt.Sprintf("inject: type %v is shared by a parent injector and cannot be mapped in a child", typ))
+		}
+	}
+}
+
 // Maps the given reflect.Type to the given reflect.Value and returns
 // the Typemapper the mapping has been registered in.
 func (i *injector) Set(typ reflect.Type, val reflect.Value) TypeMapper {
+	return i.set(typ, val, false)
+}
+
+// Override maps typ to val like Set, replacing the value already mapped to
+// typ, if any, even in an injector created WithNoOverride, e.g.
+// Override(InterfaceOf((*Clock)(nil)), reflect.ValueOf(fakeClock)).
+func (i *injector) Override(typ reflect.Type, val reflect.Value) TypeMapper {
+	return i.set(typ, val, true)
+}
+
+// set maps typ to val. It panics if typ is already mapped in i, i was
+// created WithNoOverride and replace is false.
+func (i *injector) set(typ reflect.Type, val reflect.Value, replace bool) TypeMapper {
+	i.checkShared(typ)
+	i.mu.Lock()
+	if _, ok := i.values[typ]; ok && i.noOverride && !replace {
+		i.mu.Unlock()
+		panic(fmt.Sprintf("inject: type %v is already mapped, use Override to replace it", typ))
+	}
+	if _, ok := i.values[typ]; !ok {
+		i.order = append(i.order, typ)
+	} else if i.lastWins {
+		for n, k := range i.order {
+			if k == typ {
+				i.order = append(append(i.order[:n:n], i.order[n+1:]...), typ)
+				break
+			}
+		}
+	}
 	i.values[typ] = val
+	i.mu.Unlock()
+	i.mutated(typ, BindingSet)
 	return i
 }
 
+// Get resolves t in the following order: a value mapped to t itself, for
+// interfaces an implementor (or the combined implementors), for concrete
+// types a value mapped to an interface whose dynamic type is t, then a value
+// whose type is assignable to t, and finally the parent. The fallbacks are
+// linear scans over the mapped values and only run when nothing is mapped
+// to t directly.
+//
+// A type alias (type A = B) is the same reflect.Type as B and resolves
+// exactly like it. A defined type (type A B) is a distinct type: defined
+// interfaces resolve through their implementors, and defined types of
+// unnamed types such as func or map types resolve through assignability,
+// but a defined type of a named type, e.g. type Port int, never resolves
+// from a value mapped as int.
+//
+// Each injector is searched completely before its parent is consulted, so
+// the nearest injector able to provide t wins: a value mapped, or an
+// implementor found, in a child always takes precedence over anything
+// mapped in its parents, including a parent's explicit MapTo of the same
+// interface.
+//
+// A slice of interfaces, e.g. []Middleware, that is not mapped itself in i
+// or its parents resolves to the result of GetAll for its element type, and
+// a func() []Middleware to a func returning the result of GetAll each time it
+// is called. Having no implementor is valid: the slice is then empty, never
+// nil, so Invoke and Apply do not fail for it.
 func (i *injector) Get(t reflect.Type) reflect.Value {
+	val, _ := i.resolve(t)
+	return val
+}
+
+// resolve resolves t like Get, but also returns the error of a provider
+// that failed to construct the value.
+func (i *injector) resolve(t reflect.Type) (reflect.Value, error) {
+	val, err := i.lookup(t, nil)
+	if !val.IsValid() {
+		i.miss(t)
+	}
+	return val, err
+}
+
+// resolveArg resolves t like resolve and, if that fails without an error,
+// falls back to the default supplier registered for t, if any. The miss is
+// only reported if there is no default supplier either.
+func (i *injector) resolveArg(t reflect.Type) (reflect.Value, error) {
+	return i.resolveArgOn(t, nil)
+}
+
+// resolveArgOn resolves t like resolveArg for a provider constructing the
+// types on path.
+func (i *injector) resolveArgOn(t reflect.Type, path *construction) (reflect.Value, error) {
+	if o, ok := optionalOf(t); ok {
+		return i.resolveOptional(o, path)
+	}
+	if f, ok := futureOf(t); ok {
+		return f.bind(i), nil
+	}
+	if isParams(t) {
+		return i.resolveParams(t, path)
+	}
+
+	val, err := i.lookup(t, path)
+	if !val.IsValid() && err == nil {
+		if supply := i.defaultSupplier(t); supply != nil {
+			return supply(), nil
+		}
+	}
+	if !val.IsValid() {
+		i.miss(t)
+	}
+	return val, err
+}
+
+// lookup resolves t with get and, if nothing is mapped to t, falls back to
+// collecting the implementors of its element type for a slice of interfaces,
+// to a lazy collection for a func() []I, to a func calling a factory
+// registered with MapParamProvider, or to the adapter registered for t.
+// path holds the types whose
+// providers are being constructed by the resolution, if any.
+func (i *injector) lookup(t reflect.Type, path *construction) (reflect.Value, error) {
+	val, err := i.get(t, path)
+	if !val.IsValid() && err == nil {
+		val, err = i.fallback(t, path)
+	}
+	return val, err
+}
+
+// fallback builds the value lookup falls back to for t when nothing is
+// mapped to it, from the bindings of i and its parents.
+func (i *injector) fallback(t reflect.Type, path *construction) (reflect.Value, error) {
+	var val reflect.Value
+	if isLazyCollection(t) {
+		val = i.lazyCollection(t)
+	} else if val = i.collection(t, path); !val.IsValid() {
+		val = i.factoryFor(t)
+	}
+	if val.IsValid() {
+		return val, nil
+	}
+	return i.adapt(t, path)
+}
+
+// miss reports t to the OnMiss callback, if any.
+func (i *injector) miss(t reflect.Type) {
+	i.mu.RLock()
+	onMiss := i.onMiss
+	i.mu.RUnlock()
+	if onMiss != nil {
+		onMiss(t)
+	}
+}
+
+// defaultSupplier returns the default supplier registered for t by i or the
+// nearest of its parents, or nil.
+func (i *injector) defaultSupplier(t reflect.Type) func() reflect.Value {
+	for inj, ok := i, true; ok; inj, ok = inj.parent().(*injector) {
+		inj.mu.RLock()
+		supply := inj.defaults[t]
+		inj.mu.RUnlock()
+		if supply != nil {
+			return supply
+		}
+	}
+	return nil
+}
+
+// get resolves t like resolve, without reporting misses. Parents that are
+// injectors themselves are walked directly so only the injector Get was
+// called on reports the miss.
+func (i *injector) get(t reflect.Type, path *construction) (reflect.Value, error) {
+	val, _, err := i.getLevel(t, path)
+	return val, err
+}
+
+// getLevel resolves t like get and also returns the level of the hierarchy
+// it was resolved at, 0 being i itself.
+func (i *injector) getLevel(t reflect.Type, path *construction) (reflect.Value, int, error) {
+	for inj, level := i, 0; ; level++ {
+		val, err := inj.getLocal(t, path)
+		if val.IsValid() || err != nil || inj.parent() == nil {
+			return val, level, err
+		}
+		p, ok := inj.parent().(*injector)
+		if !ok {
+			return inj.parent().Get(t), level + 1, nil
+		}
+		inj = p
+	}
+}
+
+// getLocal resolves t in i alone.
+func (i *injector) getLocal(t reflect.Type, path *construction) (reflect.Value, error) {
+	i.mu.RLock()
 	val := i.values[t]
+	p := i.providers[t]
+	combine := i.combiners[t]
+	i.mu.RUnlock()
 
 	if val.IsValid() {
-		return val
+		return val, nil
+	}
+
+	if p != nil {
+		return i.provide(p, path)
 	}
 
 	// no concrete types found, try to find implementors
 	// if t is an interface
 	if t.Kind() == reflect.Interface {
-		for k, v := range i.values {
-			if k.Implements(t) {
-				val = v
-				break
+		if combine != nil {
+			if vals := i.implementors(t); len(vals) > 0 {
+				val = combine(vals)
+			}
+		} else {
+			if i.strict {
+				if ks := i.candidates(t); len(ks) > 1 {
+					if val = preferred(i.implementors(t)); !val.IsValid() {
+						return reflect.Value{}, fmt.Errorf("Ambiguous type %v, implemented by %v", t, ks)
+					}
+					return val, nil
+				}
+			}
+			val = i.scanImplementors(t)
+		}
+	} else {
+		// a concrete type may have been mapped to one of its interfaces
+		val = i.scan(func(k reflect.Type, v reflect.Value) bool {
+			return k.Kind() == reflect.Interface && v.IsValid() && v.Type() == t
+		})
+
+		// or a defined type to the unnamed type it is defined as, or vice versa
+		if !val.IsValid() {
+			val = i.scan(func(k reflect.Type, v reflect.Value) bool {
+				return k.Kind() != reflect.Interface && v.IsValid() && k.AssignableTo(t)
+			})
+			if val.IsValid() {
+				val = val.Convert(t)
 			}
 		}
 	}
 
-	// Still no type found, try to look it up on the parent
-	if !val.IsValid() && i.parent != nil {
-		val = i.parent.Get(t)
+	return val, nil
+}
+
+// GetNearest resolves t like Get and also returns the level of the
+// hierarchy it was found at: 0 if this injector resolves it, 1 for its
+// parent and so on. A component can tell from the level whether it uses an
+// override of its own scope or a value shared by an outer one. The last
+// result reports whether t was found at all.
+// Values nothing is mapped to but that this injector builds from the whole
+// hierarchy, like a []I of every implementor of I or the result of an
+// adapter, see Get, are reported at level 0.
+func (i *injector) GetNearest(t reflect.Type) (reflect.Value, int, bool) {
+	val, level, err := i.getLevel(t, nil)
+	if !val.IsValid() && err == nil {
+		val, err = i.fallback(t, nil)
+		level = 0
+	}
+	if !val.IsValid() {
+		i.miss(t)
+		return reflect.Value{}, 0, false
+	}
+	return val, level, true
+}
+
+// MapDefaultSupplier registers supply as the last resort for t when
+// invoking functions and applying structs. It is consulted only after the
+// Type map, providers and parents have all failed to produce t, so the
+// precedence is binding > provider > default supplier > error. Default
+// suppliers of parents apply to their children. Get never uses them, and
+// the supplied value is not cached, so supply runs each time it is needed.
+func (i *injector) MapDefaultSupplier(t reflect.Type, supply func() reflect.Value) TypeMapper {
+	i.mu.Lock()
+	i.defaults[t] = supply
+	i.mu.Unlock()
+	return i
+}
+
+// MapKeyed maps val under key, which can be any comparable value such as
+// an enum constant, a route name or a struct of comparable fields. It
+// panics if key is not comparable. Keyed values are only returned by
+// GetKeyed: Get, Apply and Invoke keep resolving from the Type map.
+func (i *injector) MapKeyed(key interface{}, val interface{}) TypeMapper {
+	if key == nil || !reflect.TypeOf(key).Comparable() {
+		panic(fmt.Sprintf("inject: key %v of type %T is not comparable", key, key))
+	}
+
+	i.mu.Lock()
+	i.keyed[key] = reflect.ValueOf(val)
+	i.mu.Unlock()
+	return i
+}
+
+// GetKeyed returns the value mapped under key with MapKeyed in this
+// injector or the nearest of its parents.
+func (i *injector) GetKeyed(key interface{}) (reflect.Value, bool) {
+	i.mu.RLock()
+	val, ok := i.keyed[key]
+	i.mu.RUnlock()
+
+	if !ok && i.parent() != nil {
+		return i.parent().GetKeyed(key)
+	}
+	return val, ok
+}
+
+// GetWhere returns the first value mapped to t or, if t is an interface, to
+// a type implementing it, for which pred returns true. Candidates are tried
+// in registration order, those of this injector before those of its
+// parents. It is an explicit query: Get, Apply and Invoke never filter by
+// predicate.
+func (i *injector) GetWhere(t reflect.Type, pred func(reflect.Value) bool) (reflect.Value, bool) {
+	i.mu.RLock()
+	var vals []reflect.Value
+	for _, k := range i.order {
+		if k == t || (t.Kind() == reflect.Interface && k.Implements(t)) {
+			vals = append(vals, i.values[k])
+		}
+	}
+	i.mu.RUnlock()
+
+	for _, v := range vals {
+		if pred(v) {
+			return v, true
+		}
 	}
 
-	return val
+	if i.parent() != nil {
+		return i.parent().GetWhere(t, pred)
+	}
+	return reflect.Value{}, false
+}
 
+// Has reports whether an argument of type t of a func given to Invoke can be
+// resolved with what is mapped and registered now in this injector and its
+// parents. Providers are not invoked: a provider counts when all of its
+// arguments can be resolved, so one failing at construction still counts.
+// An Optional, a Future, a []I and a func() []I, where I is an interface,
+// always count, and a type having an adapter counts when the argument of its
+// adapter does.
+func (i *injector) Has(t reflect.Type) bool {
+	return i.has(t, map[reflect.Type]bool{})
 }
 
+// has reports whether t can be resolved like Has, following adapters but
+// not through the types in adapting.
+func (i *injector) has(t reflect.Type, adapting map[reflect.Type]bool) bool {
+	if _, ok := optionalOf(t); ok || isLazyCollection(t) || i.factoryFor(t).IsValid() {
+		return true
+	}
+	if _, ok := futureOf(t); ok {
+		return true
+	}
+	if isParams(t) && i.hasParams(t) {
+		return true
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Interface {
+		return true
+	}
+	if i.tracePath(nil, t, "", 0, map[reflect.Type]bool{}) {
+		return true
+	}
+	fn := i.adapterFor(t)
+	if !fn.IsValid() || adapting[t] {
+		return false
+	}
+	adapting[t] = true
+	return i.has(fn.Type().In(0), adapting)
+}
+
+// Unmap removes the value mapped to exactly t in this injector, e.g. to drop
+// a request-scoped value or swap a test double out, and reports whether
+// there was one. Once removed, t resolves as if it had never been mapped
+// here: from the implementors mapped, the parents or the provider of t,
+// which stays registered and constructs the value anew, see Refresh. Values
+// already injected are not affected.
+func (i *injector) Unmap(t reflect.Type) bool {
+	if !i.unset(t) {
+		return false
+	}
+	i.mutated(t, BindingUnmapped)
+	return true
+}
+
+// unset removes the value mapped to t in i, reporting whether there was one.
+func (i *injector) unset(t reflect.Type) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.values[t]; !ok {
+		return false
+	}
+	delete(i.values, t)
+	for n, k := range i.order {
+		if k == t {
+			i.order = append(i.order[:n:n], i.order[n+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Keys returns the types mapped in this injector, in registration order,
+// e.g. for tests and debugging tools. Types of providers appear once their
+// value is constructed; parents are not included, GetNearest tells whether
+// a type resolves from this injector or from a parent.
+func (i *injector) Keys() []reflect.Type {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return append([]reflect.Type(nil), i.order...)
+}
+
+// Len returns the number of types mapped in this injector, see Keys.
+func (i *injector) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.order)
+}
+
+// Peek returns the value mapped to exactly t in this injector. It is a
+// single map lookup: implementors and assignable types are not considered,
+// parents are not consulted and providers that have not constructed their
+// value yet are not invoked, so a value only reachable through Get is
+// reported as missing.
+func (i *injector) Peek(t reflect.Type) (reflect.Value, bool) {
+	i.mu.RLock()
+	val, ok := i.values[t]
+	i.mu.RUnlock()
+	return val, ok
+}
+
+// scanImplementors returns the first registered implementor of the
+// interface t or, with WithLastImplementorWins, the last one.
+func (i *injector) scanImplementors(t reflect.Type) reflect.Value {
+	if !i.lastWins {
+		return i.scan(func(k reflect.Type, v reflect.Value) bool {
+			return k.Implements(t)
+		})
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for n := len(i.order) - 1; n >= 0; n-- {
+		if k := i.order[n]; k.Implements(t) {
+			return i.values[k]
+		}
+	}
+	return reflect.Value{}
+}
+
+// scan returns the first mapped value, in registration order, for which
+// match returns true.
+func (i *injector) scan(match func(reflect.Type, reflect.Value) bool) reflect.Value {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for _, k := range i.order {
+		if v := i.values[k]; match(k, v) {
+			return v
+		}
+	}
+	return reflect.Value{}
+}
+
+// GetAll returns the values mapped to t or, if t is an interface, to any
+// type implementing it, in i and then in its parents, constructing the
+// values of matching providers that have not been used yet. A concrete type
+// mapped in a child hides the value mapped to the same type in a parent,
+// like it does for Get. Bindings to an interface, e.g. with MapTo, are
+// merged instead: a child binding an implementor of an interface its
+// parent has bound too gets both from GetAll, while Get prefers the one of
+// the child. The result is sorted by Order() for values implementing Ordered;
+// ties and unordered values keep their registration order, the values of a
+// child coming before those of its parents. The result is empty, but not
+// nil, if nothing matches.
+// This makes GetAll suitable to fetch every service marked with an
+// interface, even an empty marker interface, across the unrelated concrete
+// types they are mapped by. A pointer, map, chan or func mapped under
+// several types, e.g. by Map and by MapTo, is only returned once, in the
+// position of its first registration.
+func (i *injector) GetAll(t reflect.Type) []reflect.Value {
+	return i.getAll(t, nil)
+}
+
+// getAll collects the values like GetAll for a provider constructing the
+// types on path.
+func (i *injector) getAll(t reflect.Type, path *construction) []reflect.Value {
+	vals := []reflect.Value{}
+	seen := make(map[reflect.Type]bool)
+	for inj := Injector(i); inj != nil; {
+		p, ok := inj.(*injector)
+		if !ok {
+			for _, v := range inj.GetAll(t) {
+				if !seen[v.Type()] {
+					vals = append(vals, v)
+				}
+			}
+			break
+		}
+		vals = append(vals, p.all(t, seen, path)...)
+		inj = p.parent()
+	}
+	vals = dedup(vals)
+
+	sort.SliceStable(vals, func(a, b int) bool {
+		return order(vals[a]) < order(vals[b])
+	})
+
+	return vals
+}
+
+// all returns the values i maps to t or to a type implementing t, if t is
+// an interface, skipping the concrete types in seen and adding the others to
+// it; keys that are interfaces are never skipped. The mapped values come
+// first, followed by the values of the matching
+// providers that have not been constructed yet, which are constructed now.
+// Providers failing to construct their value are left out.
+func (i *injector) all(t reflect.Type, seen map[reflect.Type]bool, path *construction) []reflect.Value {
+	matches := func(k reflect.Type) bool {
+		return (k == t || (t.Kind() == reflect.Interface && k.Implements(t))) && (k.Kind() == reflect.Interface || !seen[k])
+	}
+
+	i.mu.RLock()
+	var vals []reflect.Value
+	for _, k := range i.order {
+		if matches(k) {
+			seen[k] = true
+			vals = append(vals, i.values[k])
+		}
+	}
+	var pending []*provider
+	for _, k := range i.provided {
+		if matches(k) {
+			seen[k] = true
+			pending = append(pending, i.providers[k])
+		}
+	}
+	i.mu.RUnlock()
+
+	for _, p := range pending {
+		if v, err := i.provide(p, path); err == nil {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
+
+// dedup removes the values referring to the same pointer, map, chan or func
+// as an earlier value of vals.
+func dedup(vals []reflect.Value) []reflect.Value {
+	type ref struct {
+		typ reflect.Type
+		ptr uintptr
+	}
+	seen := make(map[ref]bool)
+	out := vals[:0]
+	for _, v := range vals {
+		e := v
+		if e.Kind() == reflect.Interface {
+			e = e.Elem()
+		}
+		switch e.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func:
+			r := ref{e.Type(), e.Pointer()}
+			if seen[r] {
+				continue
+			}
+			seen[r] = true
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// order returns the Order() of v if it implements Ordered, 0 otherwise.
+func order(v reflect.Value) int {
+	if o, ok := v.Interface().(Ordered); ok {
+		return o.Order()
+	}
+	return 0
+}
+
+// preferred returns the one of vals with the lowest order, see Ordered, or
+// the zero Value if several share the lowest order.
+func preferred(vals []reflect.Value) reflect.Value {
+	var best reflect.Value
+	tie := false
+	for _, v := range vals {
+		switch {
+		case !best.IsValid() || order(v) < order(best):
+			best, tie = v, false
+		case order(v) == order(best):
+			tie = true
+		}
+	}
+	if tie {
+		return reflect.Value{}
+	}
+	return best
+}
+
+// candidates returns the mapped types that implement the interface t, in
+// registration order.
+func (i *injector) candidates(t reflect.Type) []reflect.Type {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var ks []reflect.Type
+	for _, k := range i.order {
+		if k.Implements(t) {
+			ks = append(ks, k)
+		}
+	}
+	return ks
+}
+
+// implementors returns the mapped values whose type implements the interface
+// t, in registration order.
+func (i *injector) implementors(t reflect.Type) []reflect.Value {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var vals []reflect.Value
+	for _, k := range i.order {
+		if k.Implements(t) {
+			vals = append(vals, i.values[k])
+		}
+	}
+	return vals
+}
+
+// parentLink holds the parent of an injector, which SetParent may replace
+// while other goroutines resolve through it.
+type parentLink struct {
+	Injector
+}
+
+// parent returns the parent of i, or nil.
+func (i *injector) parent() Injector {
+	if l := i.up.Load(); l != nil {
+		return l.Injector
+	}
+	return nil
+}
+
+// SetParent sets the parent of the injector. It panics with an error
+// wrapping ErrCircularDependency if the injector is parent itself or one of
+// its ancestors, which would make every resolution missing a value loop up
+// the parent chain forever.
 func (i *injector) SetParent(parent Injector) {
-	i.parent = parent
+	parent = unwrap(parent)
+	for p, ok := parent.(*injector); ok; p, ok = p.parent().(*injector) {
+		if p == i {
+			panic(fmt.Errorf("inject: the injector cannot be its own ancestor: %w", ErrCircularDependency))
+		}
+	}
+	i.up.Store(&parentLink{parent})
+
+	i.mu.RLock()
+	types := append([]reflect.Type(nil), i.order...)
+	for typ := range i.providers {
+		types = append(types, typ)
+	}
+	i.mu.RUnlock()
+
+	for _, typ := range types {
+		i.checkShared(typ)
+	}
 }
 
+func (i *injector) OnMiss(fn func(reflect.Type)) {
+	i.mu.Lock()
+	i.onMiss = fn
+	i.mu.Unlock()
+}
+
+// On registers handlers for the events fired for key. Each handler takes an
+// Event, optionally preceded by a context.Context, followed by arguments
+// resolved from the injector. It panics if a handler is not of that shape
+// or one of its arguments cannot be resolved with the current bindings.
 func (i *injector)On(key string, handlers ...Handler) {
 	for _, h := range handlers {
-		validateHandler(h)
+		i.checkHandler(key, h)
 	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.checkFrozen(key)
 	if i.handlers[key] == nil {
 		i.handlers[key] = handlers
 	} else {
 		i.handlers[key] = append(i.handlers[key], handlers...)
 	}
-	return i
 }
+
+// sourceHandler is a handler registered with OnFrom.
+type sourceHandler struct {
+	src     Injector
+	handler Handler
+}
+
+// OnFrom registers handler for key like On, but run only invokes it for
+// events whose Src is src. Events keep the injector that fired them as Src
+// when they are forwarded to a parent, so a parent can tell apart events of
+// its children, as long as the child forwarding the event has no handlers
+// of its own for key.
+func (i *injector) OnFrom(src Injector, key string, handler Handler) {
+	i.checkHandler(key, handler)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.checkFrozen(key)
+	i.handlers[key] = append(i.handlers[key], sourceHandler{unwrap(src), handler})
+}
+
+// checkFrozen panics if the handlers of i are frozen. i.mu must be held.
+func (i *injector) checkFrozen(key string) {
+	if i.frozen.Load() {
+		panic(fmt.Errorf("inject: cannot register a handler for %q after Start: %w", key, ErrHandlersFrozen))
+	}
+}
+
+// handlersFor returns the handlers registered with i for key. Frozen
+// handlers are read without locking.
+func (i *injector) handlersFor(key string) []Handler {
+	if i.frozen.Load() {
+		return i.handlers[key]
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.handlers[key]
+}
+
+// hasHandlers reports whether i or one of its parents has handlers for key.
+// A parent that is not created by New is assumed to handle every key.
+func (i *injector) hasHandlers(key string) bool {
+	for inj := i; ; {
+		if inj.handlersFor(key) != nil {
+			return true
+		}
+		if inj.parent() == nil {
+			return false
+		}
+		p, ok := inj.parent().(*injector)
+		if !ok {
+			return true
+		}
+		inj = p
+	}
+}
+
+// DeclareEvent declares that data fired for key has the type of sample.
+// Keys that have not been declared accept data of any type.
+// The declaration also applies to the events fired for key by children of
+// the injector. It panics if sample is nil.
+func (i *injector) DeclareEvent(key string, sample interface{}) {
+	if sample == nil {
+		panic(fmt.Sprintf("inject: event %q declared with a nil sample", key))
+	}
+	i.mu.Lock()
+	i.payloads[key] = reflect.TypeOf(sample)
+	i.mu.Unlock()
+}
+
+// checkPayload panics if key has been declared by i or one of its parents
+// and data is not assignable to a declared type.
+func (i *injector) checkPayload(key string, data interface{}) {
+	for inj, ok := i, true; ok; inj, ok = inj.parent().(*injector) {
+		inj.mu.RLock()
+		want, declared := inj.payloads[key]
+		inj.mu.RUnlock()
+		if declared {
+			checkPayloadType(key, want, data)
+		}
+	}
+}
+
+// checkPayloadType panics if data is not assignable to want.
+func checkPayloadType(key string, want reflect.Type, data interface{}) {
+	got := reflect.TypeOf(data)
+	if got == nil {
+		switch want.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+			return
+		}
+	} else if got.AssignableTo(want) {
+		return
+	}
+	panic(fmt.Sprintf("inject: event %q expects data of type %v, got %v", key, want, got))
+}
+
+// Fire sends an event for key carrying data to the event loop, if this
+// injector or one of its parents has handlers for key. It panics if data
+// does not match the type declared for key with DeclareEvent.
 func (i *injector)Fire(key string, data interface{}) {
-	if i.handlers[key] != nil {
+	i.checkPayload(key, data)
+	if i.hasHandlers(key) {
 		e := Event{
 			Src:i,
 			Type:key,
@@ -247,36 +1995,64 @@ func (i *injector)Fire(key string, data interface{}) {
 	}
 }
 
+// run dispatches e to the handlers registered for its type, skipping those
+// registered with OnFrom for another source. Events nobody handles here are
+// forwarded, unchanged, to the parent.
+// Handlers are invoked with the context of the event, see Event.Context,
+// which they can take as a context.Context argument. The Event itself is
+// pushed onto that context with PushScope rather than mapped, so concurrent
+// dispatches each see their own. Once that context is done, the handlers
+// that have not run yet are skipped.
 func (i *injector)run(e Event) {
-	hs := i.handlers[e.Type]
+	hs := i.handlersFor(e.Type)
 	if hs == nil {
-		if i.parent == nil {
+		if i.parent() == nil {
 			panic(fmt.Sprintf("%s %s", "unknow event type ", e.Type))
 		}
-		i.parent.Events <- e
+		i.parent().Events() <- e
 	} else {
-		i.Set(Event.Type, e)
+		var replies []handlerReply
+		ctx := e.Context()
+		scoped := PushScope(ctx, e)
 		for _, h := range hs {
-			i.Invoke(h)
+			if ctx.Err() != nil {
+				break
+			}
+			if sh, ok := h.(sourceHandler); ok {
+				if sh.src != e.Src {
+					continue
+				}
+				h = sh.handler
+			}
+			out, err := i.invokeHandler(scoped, e, h)
+			if e.respond != nil && (err != nil || len(out) > 0) {
+				replies = append(replies, handlerReply{out, err})
+			}
+		}
+		if e.respond != nil {
+			e.respond <- respond(e.Type, replies)
 		}
 	}
 }
 
 func (i *injector)Start() {
-	go func() {
-		for {
-			select {
-			case e := <-i.events:
-				i.run(e)
-			case <-i.stopped:
-				return
-			}
-		}
-	}()
+	i.StartCtx(context.Background())
 }
 
+// Stop stops the event loop once it is done with the event it is
+// dispatching, if any, then runs the stop hooks, see StopHooks. It returns
+// immediately if the loop has already stopped by itself, see StartCtx.
 func (i *injector)Stop() {
-	i.stopped <- true
+	i.mu.RLock()
+	done := i.loopDone
+	i.mu.RUnlock()
+
+	select {
+	case i.stopped <- true:
+		<-done
+	case <-done:
+	}
+	i.hooksFailed(i.StopHooks(context.Background()))
 }
 
 /*func (i *injector)All() {
@@ -1,9 +1,15 @@
 package inject_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/codegangsta/inject"
+	"io"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -37,6 +43,21 @@ func refute(t *testing.T, a interface{}, b interface{}) {
 	}
 }
 
+// panicMessage calls f and returns the message it panicked with, or "" if
+// it did not panic.
+func panicMessage(f func()) (msg string) {
+	defer func() {
+		switch r := recover().(type) {
+		case string:
+			msg = r
+		case error:
+			msg = r.Error()
+		}
+	}()
+	f()
+	return ""
+}
+
 func Test_InjectorInvoke(t *testing.T) {
 	injector := inject.New()
 	expect(t, injector == nil, false)
@@ -97,6 +118,35 @@ func Test_InjectorApply(t *testing.T) {
 	expect(t, s.Dep3, "")
 }
 
+func Test_InjectorMapWith(t *testing.T) {
+	injector := inject.New()
+
+	calls := 0
+	injector.MapWith(&Greeter{"Jeremy"}, func(v reflect.Value) reflect.Value {
+		calls++
+		return reflect.ValueOf(&Greeter{v.Interface().(*Greeter).Name + " Jr."})
+	})
+
+	typ := reflect.TypeOf(&Greeter{})
+	expect(t, injector.Get(typ).Interface().(*Greeter).Name, "Jeremy Jr.")
+	expect(t, injector.Get(typ).Interface().(*Greeter).Name, "Jeremy Jr.")
+	expect(t, calls, 1)
+	msg := panicMessage(func() {
+		injector.MapWith("some dependency", func(v reflect.Value) reflect.Value {
+			return reflect.ValueOf(42)
+		})
+	})
+	expect(t, msg, "inject: MapWith transform returned int, which is not assignable to string")
+	expect(t, injector.Get(reflect.TypeOf("")).IsValid(), false)
+
+	msg = panicMessage(func() {
+		injector.MapWith("some dependency", func(v reflect.Value) reflect.Value {
+			return reflect.Value{}
+		})
+	})
+	expect(t, msg, "inject: MapWith transform returned <nil>, which is not assignable to string")
+}
+
 func Test_InterfaceOf(t *testing.T) {
 	iType := inject.InterfaceOf((*SpecialString)(nil))
 	expect(t, iType.Kind(), reflect.Interface)
@@ -112,6 +162,28 @@ func Test_InterfaceOf(t *testing.T) {
 	iType = inject.InterfaceOf((*testing.T)(nil))
 }
 
+func Test_InterfaceOfErrors(t *testing.T) {
+	interfaceOf := func(value interface{}) string {
+		return panicMessage(func() { inject.InterfaceOf(value) })
+	}
+
+	expect(t, interfaceOf((*fmt.Stringer)(nil)), "")
+	expect(t, interfaceOf((**fmt.Stringer)(nil)), "")
+
+	msg := interfaceOf(&Greeter{})
+	expect(t, strings.Contains(msg, "expected (*MyInterface)(nil), got *inject_test.Greeter"), true)
+	expect(t, strings.Contains(msg, "concrete type inject_test.Greeter"), true)
+
+	msg = interfaceOf((**Greeter)(nil))
+	expect(t, strings.Contains(msg, "got **inject_test.Greeter"), true)
+
+	msg = interfaceOf(Greeter{})
+	expect(t, strings.Contains(msg, "got inject_test.Greeter (not a pointer)"), true)
+
+	msg = interfaceOf(nil)
+	expect(t, strings.Contains(msg, "got nil"), true)
+}
+
 func Test_InjectorSet(t *testing.T) {
 	injector := inject.New()
 	typ := reflect.TypeOf("string")
@@ -157,3 +229,1313 @@ func TestInjectImplementors(t *testing.T) {
 
 	expect(t, injector.Get(inject.InterfaceOf((*fmt.Stringer)(nil))).IsValid(), true)
 }
+
+func Test_InjectorMapCombiner(t *testing.T) {
+	injector := inject.New()
+
+	buf := &bytes.Buffer{}
+	sb := &strings.Builder{}
+	injector.Map(buf).Map(sb)
+	injector.MapCombiner((*io.Writer)(nil), func(vals []reflect.Value) reflect.Value {
+		writers := make([]io.Writer, len(vals))
+		for i, v := range vals {
+			writers[i] = v.Interface().(io.Writer)
+		}
+		return reflect.ValueOf(io.MultiWriter(writers...))
+	})
+
+	_, err := injector.Invoke(func(w io.Writer) {
+		fmt.Fprint(w, "hello")
+	})
+	expect(t, err, nil)
+	expect(t, buf.String(), "hello")
+	expect(t, sb.String(), "hello")
+}
+
+func Test_InjectorOnMiss(t *testing.T) {
+	parent := inject.New()
+	parent.Map("some dependency")
+	parentMisses := 0
+	parent.OnMiss(func(reflect.Type) {
+		parentMisses++
+	})
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	var missed []reflect.Type
+	injector.OnMiss(func(typ reflect.Type) {
+		missed = append(missed, typ)
+	})
+
+	expect(t, injector.Get(reflect.TypeOf("string")).IsValid(), true)
+	expect(t, len(missed), 0)
+
+	expect(t, injector.Get(reflect.TypeOf(11)).IsValid(), false)
+	expect(t, len(missed), 1)
+	expect(t, missed[0], reflect.TypeOf(11))
+	expect(t, parentMisses, 0)
+
+	// a default supplier turns the miss into a hit for Invoke
+	injector.MapDefaultSupplier(reflect.TypeOf(11), func() reflect.Value { return reflect.ValueOf(42) })
+	_, err := injector.Invoke(func(n int) {})
+	expect(t, err, nil)
+	expect(t, len(missed), 1)
+
+	_, err = injector.Invoke(func(f float64) {})
+	refute(t, err, nil)
+	expect(t, len(missed), 2)
+	expect(t, missed[1], reflect.TypeOf(1.5))
+}
+
+type Middleware interface {
+	Handle() string
+}
+
+type authMiddleware struct{}
+
+func (authMiddleware) Handle() string { return "auth" }
+func (authMiddleware) Order() int     { return 2 }
+
+type logMiddleware struct{}
+
+func (logMiddleware) Handle() string { return "log" }
+func (logMiddleware) Order() int     { return -1 }
+
+type gzipMiddleware struct{}
+
+func (gzipMiddleware) Handle() string { return "gzip" }
+
+type corsMiddleware struct{}
+
+func (corsMiddleware) Handle() string { return "cors" }
+
+func Test_InjectorGetAllOrdered(t *testing.T) {
+	injector := inject.New()
+	injector.Map(authMiddleware{}).Map(gzipMiddleware{}).Map(logMiddleware{}).Map(corsMiddleware{})
+
+	vals := injector.GetAll(inject.InterfaceOf((*Middleware)(nil)))
+	expect(t, len(vals), 4)
+
+	names := make([]string, len(vals))
+	for i, v := range vals {
+		names[i] = v.Interface().(Middleware).Handle()
+	}
+	expect(t, strings.Join(names, ","), "log,gzip,cors,auth")
+}
+
+// handles returns the result of Handle for each of the middlewares.
+func handles(ms []Middleware) string {
+	names := make([]string, len(ms))
+	for i, m := range ms {
+		names[i] = m.Handle()
+	}
+	return strings.Join(names, ",")
+}
+
+func Test_InjectorGetAllParents(t *testing.T) {
+	parent := inject.New()
+	parent.Map(authMiddleware{}).Map(corsMiddleware{})
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.Map(gzipMiddleware{}).Map(logMiddleware{})
+
+	// cors of the parent is hidden by the child's own cors
+	injector.Map(corsMiddleware{})
+
+	vals := injector.GetAll(inject.InterfaceOf((*Middleware)(nil)))
+	ms := make([]Middleware, len(vals))
+	for i, v := range vals {
+		ms[i] = v.Interface().(Middleware)
+	}
+	expect(t, handles(ms), "log,gzip,cors,auth")
+}
+
+type MiddlewareChain struct {
+	Middlewares []Middleware `inject:"t"`
+}
+
+func Test_InjectorSliceOfInterfaces(t *testing.T) {
+	parent := inject.New()
+	parent.Map(authMiddleware{})
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.Map(gzipMiddleware{}).Map(logMiddleware{})
+
+	chain := MiddlewareChain{}
+	expect(t, injector.Apply(&chain), nil)
+	expect(t, handles(chain.Middlewares), "log,gzip,auth")
+
+	_, err := injector.Invoke(func(ms []Middleware) {
+		expect(t, handles(ms), "log,gzip,auth")
+	})
+	expect(t, err, nil)
+
+	// an explicitly mapped slice wins over the collected implementors
+	parent.Map([]Middleware{corsMiddleware{}})
+	expect(t, injector.Apply(&chain), nil)
+	expect(t, handles(chain.Middlewares), "cors")
+
+	// without implementors the slice is empty, but not nil
+	empty := inject.New()
+	_, err = empty.Invoke(func(ms []Middleware) {
+		expect(t, ms == nil, false)
+		expect(t, len(ms), 0)
+	})
+	expect(t, err, nil)
+
+	chain = MiddlewareChain{}
+	expect(t, empty.Apply(&chain), nil)
+	expect(t, chain.Middlewares == nil, false)
+	expect(t, len(chain.Middlewares), 0)
+
+	all := empty.GetAll(reflect.TypeOf((*Middleware)(nil)).Elem())
+	expect(t, all == nil, false)
+	expect(t, len(all), 0)
+}
+
+func Test_InjectorGetAllConcrete(t *testing.T) {
+	injector := inject.New()
+	injector.Map("some dependency")
+
+	expect(t, len(injector.GetAll(reflect.TypeOf("string"))), 1)
+	expect(t, len(injector.GetAll(reflect.TypeOf(11))), 0)
+}
+
+func Test_InjectorGetDynamicType(t *testing.T) {
+	injector := inject.New()
+	g := &Greeter{"Jeremy"}
+	injector.MapTo(g, (*fmt.Stringer)(nil))
+
+	val := injector.Get(reflect.TypeOf(g))
+	expect(t, val.IsValid(), true)
+	expect(t, val.Interface(), g)
+
+	// an exact binding takes precedence over the dynamic type of an interface
+	g2 := &Greeter{"Jane"}
+	injector.Map(g2)
+	expect(t, injector.Get(reflect.TypeOf(g)).Interface(), g2)
+}
+
+type StringerAlias = fmt.Stringer
+
+type DefinedStringer fmt.Stringer
+
+type GreetFunc func() string
+
+type Port int
+
+type AliasStruct struct {
+	Alias   StringerAlias   `inject:"t"`
+	Defined DefinedStringer `inject:"t"`
+	Greet   GreetFunc       `inject:"t"`
+}
+
+func Test_InjectorApplyAliasAndDefinedTypes(t *testing.T) {
+	injector := inject.New()
+	g := &Greeter{"Jeremy"}
+	injector.MapTo(g, (*fmt.Stringer)(nil))
+	injector.Map(func() string { return "hi" })
+	injector.Map(8080)
+
+	expect(t, reflect.TypeOf((*StringerAlias)(nil)).Elem(), reflect.TypeOf((*fmt.Stringer)(nil)).Elem())
+
+	s := AliasStruct{}
+	err := injector.Apply(&s)
+	expect(t, err, nil)
+	expect(t, s.Alias, fmt.Stringer(g))
+	expect(t, s.Defined, DefinedStringer(g))
+	expect(t, s.Greet(), "hi")
+
+	expect(t, injector.Get(reflect.TypeOf(Port(0))).IsValid(), false)
+}
+
+type childGreeter struct{}
+
+func (childGreeter) String() string { return "child" }
+
+type parentGreeter struct{}
+
+func (parentGreeter) String() string { return "parent" }
+
+type StringerStruct struct {
+	Stringer fmt.Stringer `inject:"t"`
+}
+
+func Test_InjectorChildOverridesParent(t *testing.T) {
+	parent := inject.New()
+	parent.MapTo(parentGreeter{}, (*fmt.Stringer)(nil))
+
+	// explicit child binding over explicit parent binding
+	child := inject.New()
+	child.SetParent(parent)
+	child.MapTo(childGreeter{}, (*fmt.Stringer)(nil))
+	_, err := child.Invoke(func(s fmt.Stringer) {
+		expect(t, s.String(), "child")
+	})
+	expect(t, err, nil)
+
+	// child implementor over explicit parent binding
+	child = inject.New()
+	child.SetParent(parent)
+	child.Map(childGreeter{})
+	s := StringerStruct{}
+	expect(t, child.Apply(&s), nil)
+	expect(t, s.Stringer.String(), "child")
+
+	// explicit child binding over parent implementor
+	parent = inject.New()
+	parent.Map(parentGreeter{})
+	child = inject.New()
+	child.SetParent(parent)
+	child.MapTo(childGreeter{}, (*fmt.Stringer)(nil))
+	s = StringerStruct{}
+	expect(t, child.Apply(&s), nil)
+	expect(t, s.Stringer.String(), "child")
+
+	// nothing in the child, the parent's implementor is used
+	child = inject.New()
+	child.SetParent(parent)
+	s = StringerStruct{}
+	expect(t, child.Apply(&s), nil)
+	expect(t, s.Stringer.String(), "parent")
+}
+
+func Test_InjectorMapShared(t *testing.T) {
+	root := inject.New()
+	db := &DB{"postgres://"}
+	root.MapShared(db)
+
+	child := inject.New()
+	child.SetParent(root)
+	grandchild := inject.New()
+	grandchild.SetParent(child)
+
+	expect(t, grandchild.Get(reflect.TypeOf(db)).Interface(), db)
+
+	refute(t, panicMessage(func() { child.Map(&DB{"sqlite://"}) }), "")
+	refute(t, panicMessage(func() { grandchild.Map(&DB{"sqlite://"}) }), "")
+	refute(t, panicMessage(func() { child.MapProvider(func() *DB { return nil }) }), "")
+	expect(t, grandchild.Get(reflect.TypeOf(db)).Interface(), db)
+
+	orphan := inject.New()
+	orphan.Map(&DB{"sqlite://"})
+	refute(t, panicMessage(func() { orphan.SetParent(root) }), "")
+
+	// other types can still be mapped and the owner may replace the value
+	expect(t, panicMessage(func() { child.Map("some dependency") }), "")
+	expect(t, panicMessage(func() { root.Map(&DB{"mysql://"}) }), "")
+}
+
+func Test_InjectorInvokeAndMap(t *testing.T) {
+	injector := inject.New()
+	injector.Map(Config{"postgres://"})
+
+	err := injector.InvokeAndMap(func(c Config) (*DB, *Cache, error) {
+		db := &DB{c.DSN}
+		return db, &Cache{db}, nil
+	})
+	expect(t, err, nil)
+
+	_, err = injector.Invoke(func(db *DB, c *Cache) {
+		expect(t, db.DSN, "postgres://")
+		expect(t, c.DB, db)
+	})
+	expect(t, err, nil)
+}
+
+func Test_InjectorInvokeAndMapErrors(t *testing.T) {
+	injector := inject.New()
+
+	boom := errors.New("boom")
+	err := injector.InvokeAndMap(func() (*DB, error) {
+		return &DB{}, boom
+	})
+	expect(t, err, boom)
+	expect(t, injector.Get(reflect.TypeOf(&DB{})).IsValid(), false)
+
+	called := false
+	err = injector.InvokeAndMap(func() (*DB, *DB) {
+		called = true
+		return nil, nil
+	})
+	refute(t, err, nil)
+
+	injector.Map("some dependency")
+	err = injector.InvokeAndMap(func() string {
+		called = true
+		return "another dep"
+	})
+	refute(t, err, nil)
+	expect(t, called, false)
+}
+
+type Component struct {
+	Scope inject.Injector `inject:"t"`
+}
+
+func Test_InjectorApplyChildInjector(t *testing.T) {
+	injector := inject.New()
+	injector.Map("some dependency")
+
+	c := Component{}
+	expect(t, injector.Apply(&c), nil)
+	refute(t, c.Scope, nil)
+	refute(t, c.Scope, injector)
+
+	expect(t, c.Scope.Get(reflect.TypeOf("")).Interface(), "some dependency")
+
+	c.Scope.Map(11)
+	expect(t, c.Scope.Get(reflect.TypeOf(11)).IsValid(), true)
+	expect(t, injector.Get(reflect.TypeOf(11)).IsValid(), false)
+
+	c2 := Component{}
+	expect(t, injector.Apply(&c2), nil)
+	refute(t, c2.Scope, c.Scope)
+}
+
+func Test_InjectorApplyChildInjectorOptions(t *testing.T) {
+	injector := inject.New(inject.WithStrictImplementors())
+
+	c := Component{}
+	expect(t, injector.Apply(&c), nil)
+	c.Scope.Map(childGreeter{}).Map(parentGreeter{})
+	_, err := c.Scope.Invoke(func(s fmt.Stringer) {})
+	refute(t, err, nil)
+
+	// an explicit binding wins over the child
+	shared := inject.New()
+	injector.MapTo(shared, (*inject.Injector)(nil))
+	expect(t, injector.Apply(&c), nil)
+	expect(t, c.Scope, shared)
+}
+
+func Test_InjectorPeek(t *testing.T) {
+	parent := inject.New()
+	parent.Map(11)
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.Map("some dependency")
+	injector.Map(&Greeter{"Jeremy"})
+	injector.MapProvider(func() *DB { return &DB{} })
+
+	val, ok := injector.Peek(reflect.TypeOf(""))
+	expect(t, ok, true)
+	expect(t, val.Interface(), "some dependency")
+
+	// inherited
+	_, ok = injector.Peek(reflect.TypeOf(11))
+	expect(t, ok, false)
+	expect(t, injector.Get(reflect.TypeOf(11)).IsValid(), true)
+
+	// implementor
+	_, ok = injector.Peek(inject.InterfaceOf((*fmt.Stringer)(nil)))
+	expect(t, ok, false)
+
+	// provider, only once constructed
+	_, ok = injector.Peek(reflect.TypeOf(&DB{}))
+	expect(t, ok, false)
+	injector.Get(reflect.TypeOf(&DB{}))
+	_, ok = injector.Peek(reflect.TypeOf(&DB{}))
+	expect(t, ok, true)
+}
+
+func Test_InjectorApplyAmbiguousImplementors(t *testing.T) {
+	injector := inject.New()
+	injector.Map(parentGreeter{}).Map(childGreeter{})
+
+	// first registered implementor wins by default
+	s := StringerStruct{}
+	expect(t, injector.Apply(&s), nil)
+	expect(t, s.Stringer.String(), "parent")
+
+	strict := inject.New(inject.WithStrictImplementors())
+	strict.Map(parentGreeter{}).Map(childGreeter{})
+
+	s = StringerStruct{}
+	err := strict.Apply(&s)
+	refute(t, err, nil)
+	expect(t, strings.Contains(err.Error(), "Field Stringer"), true)
+	expect(t, strings.Contains(err.Error(), "inject_test.parentGreeter"), true)
+	expect(t, strings.Contains(err.Error(), "inject_test.childGreeter"), true)
+	expect(t, s.Stringer, nil)
+
+	// an explicit binding is never ambiguous
+	strict.MapTo(childGreeter{}, (*fmt.Stringer)(nil))
+	expect(t, strict.Apply(&s), nil)
+	expect(t, s.Stringer.String(), "child")
+}
+
+type Logger interface {
+	Log(string) string
+}
+
+type prefixLogger struct {
+	prefix string
+}
+
+func (l prefixLogger) Log(msg string) string {
+	return l.prefix + msg
+}
+
+func (l prefixLogger) WithName(name string) Logger {
+	return prefixLogger{l.prefix + name + ": "}
+}
+
+type UserService struct {
+	Logger Logger `inject:",named"`
+	Plain  Logger `inject:"t"`
+}
+
+func Test_InjectorApplyNamed(t *testing.T) {
+	injector := inject.New()
+	injector.MapTo(prefixLogger{"app/"}, (*Logger)(nil))
+
+	s := UserService{}
+	expect(t, injector.Apply(&s), nil)
+	expect(t, s.Logger.Log("hello"), "app/UserService: hello")
+	expect(t, s.Plain.Log("hello"), "app/hello")
+}
+
+func benchmarkInjector() inject.Injector {
+	injector := inject.New()
+	injector.Map("some dependency").Map(11).Map(&Greeter{"Jeremy"})
+	return injector
+}
+
+func handler(s string, n int, g *Greeter) {}
+
+func BenchmarkInjectorInvoke(b *testing.B) {
+	injector := benchmarkInjector()
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		injector.Invoke(handler)
+	}
+}
+
+type benchmarkDeps struct {
+	S     string   `inject:"t"`
+	N     int      `inject:"t" json:"n"`
+	G     *Greeter `inject:"t"`
+	Plain string
+}
+
+func BenchmarkInjectorApply(b *testing.B) {
+	injector := benchmarkInjector()
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		injector.Apply(&benchmarkDeps{})
+	}
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Log(msg string) string { return "" }
+
+func Test_InjectorMapDefaultSupplier(t *testing.T) {
+	parent := inject.New()
+	loggerType := inject.InterfaceOf((*Logger)(nil))
+	parent.MapDefaultSupplier(loggerType, func() reflect.Value {
+		return reflect.ValueOf(noopLogger{})
+	})
+
+	injector := inject.New()
+	injector.SetParent(parent)
+
+	// the default is a last resort and never used by Get
+	expect(t, injector.Get(loggerType).IsValid(), false)
+
+	_, err := injector.Invoke(func(l Logger) {
+		expect(t, l, Logger(noopLogger{}))
+	})
+	expect(t, err, nil)
+
+	s := UserService{}
+	expect(t, injector.Apply(&s), nil)
+	expect(t, s.Plain, Logger(noopLogger{}))
+
+	// a provider takes precedence over the default
+	injector.MapProvider(func() Logger { return prefixLogger{"provided/"} })
+	_, err = injector.Invoke(func(l Logger) {
+		expect(t, l.Log("x"), "provided/x")
+	})
+	expect(t, err, nil)
+}
+
+func Test_InjectorLastImplementorWins(t *testing.T) {
+	stringerType := inject.InterfaceOf((*fmt.Stringer)(nil))
+
+	injector := inject.New(inject.WithLastImplementorWins())
+	injector.Map(parentGreeter{}).Map(childGreeter{})
+	expect(t, injector.Get(stringerType).Interface().(fmt.Stringer).String(), "child")
+
+	// mapping a type again makes it the most recent one
+	injector.Map(parentGreeter{})
+	expect(t, injector.Get(stringerType).Interface().(fmt.Stringer).String(), "parent")
+
+	injector = inject.New()
+	injector.Map(parentGreeter{}).Map(childGreeter{})
+	expect(t, injector.Get(stringerType).Interface().(fmt.Stringer).String(), "parent")
+}
+
+func Test_Must(t *testing.T) {
+	injector := inject.Must(func(inj inject.Injector) error {
+		inj.Map("some dependency")
+		return nil
+	})
+	expect(t, injector.Get(reflect.TypeOf("")).Interface(), "some dependency")
+
+	defer func() {
+		msg, _ := recover().(string)
+		expect(t, msg, "inject: wiring the injector failed: missing config")
+	}()
+	inject.Must(func(inj inject.Injector) error {
+		return errors.New("missing config")
+	})
+	t.Error("Must did not panic")
+}
+
+type Route int
+
+const (
+	RouteHome Route = iota
+	RouteLogin
+)
+
+type RouteKey struct {
+	Method string
+	Path   string
+}
+
+func Test_InjectorMapKeyed(t *testing.T) {
+	parent := inject.New()
+	parent.MapKeyed(RouteHome, "home handler")
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.MapKeyed(RouteLogin, "login handler")
+	injector.MapKeyed(RouteKey{"GET", "/users"}, "list users")
+
+	val, ok := injector.GetKeyed(RouteLogin)
+	expect(t, ok, true)
+	expect(t, val.Interface(), "login handler")
+
+	val, ok = injector.GetKeyed(RouteHome)
+	expect(t, ok, true)
+	expect(t, val.Interface(), "home handler")
+
+	val, ok = injector.GetKeyed(RouteKey{"GET", "/users"})
+	expect(t, ok, true)
+	expect(t, val.Interface(), "list users")
+
+	_, ok = injector.GetKeyed(RouteKey{"POST", "/users"})
+	expect(t, ok, false)
+
+	// keyed values stay out of the Type map
+	expect(t, injector.Get(reflect.TypeOf("")).IsValid(), false)
+
+	defer func() {
+		refute(t, recover(), nil)
+	}()
+	injector.MapKeyed([]string{"not", "comparable"}, "value")
+}
+
+type Secret string
+
+func (s Secret) String() string { return string(s) }
+
+type secretWrapper struct {
+	Secret
+}
+
+func (s secretWrapper) String() string { return "****" }
+
+type Credentials struct {
+	Password fmt.Stringer `inject:"t"`
+	Name     string       `inject:"t"`
+}
+
+func Test_InjectorInterceptFields(t *testing.T) {
+	parent := inject.New()
+	parent.InterceptFields(func(field reflect.StructField, v reflect.Value) reflect.Value {
+		if s, ok := v.Interface().(Secret); ok {
+			return reflect.ValueOf(secretWrapper{s})
+		}
+		return v
+	})
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.MapTo(Secret("hunter2"), (*fmt.Stringer)(nil))
+	injector.Map("admin")
+
+	var fields []string
+	injector.InterceptFields(func(field reflect.StructField, v reflect.Value) reflect.Value {
+		fields = append(fields, field.Name+"="+fmt.Sprint(v.Interface()))
+		return v
+	})
+
+	c := Credentials{}
+	expect(t, injector.Apply(&c), nil)
+	expect(t, c.Password.String(), "****")
+	expect(t, c.Password.(secretWrapper).Secret, Secret("hunter2"))
+	expect(t, c.Name, "admin")
+	expect(t, strings.Join(fields, ","), "Password=****,Name=admin")
+}
+
+func Test_InjectorInterceptFieldsInvalid(t *testing.T) {
+	injector := inject.New()
+	injector.Map("admin")
+
+	var fields []string
+	injector.InterceptFields(func(field reflect.StructField, v reflect.Value) reflect.Value {
+		fields = append(fields, field.Name)
+		return v
+	})
+	expect(t, injector.Apply(&Component{}), nil)
+	expect(t, injector.Apply(&PluginHost{}), nil)
+	expect(t, strings.Join(fields, ","), "Scope,Plugins")
+
+	injector.InterceptFields(func(field reflect.StructField, v reflect.Value) reflect.Value {
+		if field.Name == "Name" {
+			return reflect.ValueOf(42)
+		}
+		return reflect.Value{}
+	})
+	injector.MapTo(Secret("hunter2"), (*fmt.Stringer)(nil))
+	err := injector.Apply(&Credentials{})
+	refute(t, err, nil)
+	expect(t, err.Error(), "Field Password: interceptor returned <nil>, which is not assignable to fmt.Stringer\nField Name: interceptor returned int, which is not assignable to string")
+
+	errs := injector.ApplyAll(&Credentials{})
+	expect(t, len(errs), 2)
+	expect(t, errs[1].Error(), "Field Name: interceptor returned int, which is not assignable to string")
+}
+
+type Backend interface {
+	Healthy() bool
+	Name() string
+}
+
+type primaryBackend struct{ healthy bool }
+
+func (b primaryBackend) Healthy() bool { return b.healthy }
+func (primaryBackend) Name() string    { return "primary" }
+
+type secondaryBackend struct{ healthy bool }
+
+func (b secondaryBackend) Healthy() bool { return b.healthy }
+func (secondaryBackend) Name() string    { return "secondary" }
+
+type fallbackBackend struct{}
+
+func (fallbackBackend) Healthy() bool { return true }
+func (fallbackBackend) Name() string  { return "fallback" }
+
+func Test_InjectorGetWhere(t *testing.T) {
+	parent := inject.New()
+	parent.Map(fallbackBackend{})
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.Map(primaryBackend{false}).Map(secondaryBackend{true})
+
+	backendType := inject.InterfaceOf((*Backend)(nil))
+	healthy := func(v reflect.Value) bool {
+		return v.Interface().(Backend).Healthy()
+	}
+
+	val, ok := injector.GetWhere(backendType, healthy)
+	expect(t, ok, true)
+	expect(t, val.Interface().(Backend).Name(), "secondary")
+
+	val, ok = injector.GetWhere(backendType, func(v reflect.Value) bool {
+		return v.Interface().(Backend).Name() == "fallback"
+	})
+	expect(t, ok, true)
+	expect(t, val.Interface().(Backend).Name(), "fallback")
+
+	_, ok = injector.GetWhere(backendType, func(reflect.Value) bool { return false })
+	expect(t, ok, false)
+}
+
+func Test_InjectorInvokeLenient(t *testing.T) {
+	injector := inject.New()
+	injector.Map("some dependency")
+
+	result, err := injector.InvokeLenient(func(reserved int, s string, g *Greeter) string {
+		expect(t, reserved, 0)
+		expect(t, g == nil, true)
+		return s
+	}, []int{0, 2})
+	expect(t, err, nil)
+	expect(t, result[0].String(), "some dependency")
+
+	_, err = injector.InvokeLenient(func(reserved int, s string) {}, []int{1})
+	refute(t, err, nil)
+
+	_, err = injector.InvokeLenient(func(s string) {}, []int{3})
+	refute(t, err, nil)
+}
+
+type Plugin interface {
+	PluginName() string
+}
+
+type authPlugin struct{}
+
+func (authPlugin) PluginName() string { return "auth" }
+
+type metricsPlugin struct{}
+
+func (metricsPlugin) PluginName() string { return "metrics" }
+
+type PluginHost struct {
+	Plugins func() []Plugin `inject:"t"`
+}
+
+func Test_InjectorApplyLazyCollection(t *testing.T) {
+	injector := inject.New()
+	injector.Map(authPlugin{})
+
+	host := PluginHost{}
+	expect(t, injector.Apply(&host), nil)
+	expect(t, len(host.Plugins()), 1)
+
+	// registered after the consumer was wired
+	injector.Map(metricsPlugin{})
+	plugins := host.Plugins()
+	expect(t, len(plugins), 2)
+	expect(t, plugins[0].PluginName(), "auth")
+	expect(t, plugins[1].PluginName(), "metrics")
+}
+
+type tracePlugin struct{}
+
+func (tracePlugin) PluginName() string { return "trace" }
+
+func Test_InjectorApplyLazyCollectionParentsAndProviders(t *testing.T) {
+	parent := inject.New()
+	parent.Map(authPlugin{})
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	constructed := 0
+	injector.MapProvider(func() metricsPlugin {
+		constructed++
+		return metricsPlugin{}
+	})
+
+	host := PluginHost{}
+	expect(t, injector.Apply(&host), nil)
+	expect(t, constructed, 0)
+
+	plugins := host.Plugins()
+	expect(t, len(plugins), 2)
+	expect(t, plugins[0].PluginName(), "metrics")
+	expect(t, plugins[1].PluginName(), "auth")
+	expect(t, len(host.Plugins()), 2)
+	expect(t, constructed, 1)
+
+	// an explicitly mapped func is used as is
+	injector.Map(func() []Plugin { return []Plugin{tracePlugin{}} })
+	expect(t, injector.Apply(&host), nil)
+	plugins = host.Plugins()
+	expect(t, len(plugins), 1)
+	expect(t, plugins[0].PluginName(), "trace")
+}
+
+type MissingDeps struct {
+	Dep1 string  `inject:"t"`
+	Dep2 *Config `inject:"t"`
+	Dep3 int     `inject:"t"`
+	Dep4 Logger  `inject:"t"`
+	Dep5 string  `inject:"t"`
+}
+
+func Test_InjectorApplyAll(t *testing.T) {
+	injector := inject.New()
+	injector.Map(42)
+
+	s := MissingDeps{}
+	errs := injector.ApplyAll(&s)
+	expect(t, len(errs), 4)
+	expect(t, errs[0].Error(), "Field Dep1: Value not found for type string")
+	expect(t, errs[1].Error(), "Field Dep2: Value not found for type *inject_test.Config")
+	expect(t, errs[2].Error(), "Field Dep4: Value not found for type inject_test.Logger")
+	expect(t, errs[3].Error(), "Field Dep5: Value not found for type string")
+	expect(t, s.Dep3, 42)
+
+	injector.Map("a dep").Map(&Config{}).MapTo(noopLogger{}, (*Logger)(nil))
+	expect(t, len(injector.ApplyAll(&s)), 0)
+	expect(t, s.Dep1, "a dep")
+
+	// Apply reports the same errors, joined
+	err := inject.New().Apply(&s)
+	expect(t, err.Error(), errors.Join(inject.New().ApplyAll(&s)...).Error())
+	expect(t, strings.Count(err.Error(), "\n"), 4)
+	expect(t, strings.HasPrefix(err.Error(), "Field Dep1: Value not found for type string\n"), true)
+
+	boom := errors.New("boom")
+	failing := inject.New()
+	failing.MapProvider(func() (*Config, error) { return nil, boom })
+	err = errors.Join(failing.ApplyAll(&MissingDeps{})...)
+	expect(t, errors.Is(err, boom), true)
+	expect(t, strings.Count(err.Error(), "\n"), 4)
+}
+
+func Test_InjectorGetNearest(t *testing.T) {
+	app := inject.New()
+	app.Map("app")
+	app.Map(&Greeter{"app"})
+	request := inject.New()
+	request.SetParent(app)
+	request.Map(&Greeter{"request"})
+	handler := inject.New()
+	handler.SetParent(request)
+	handler.Map(42)
+
+	v, level, ok := handler.GetNearest(reflect.TypeOf(0))
+	expect(t, ok, true)
+	expect(t, level, 0)
+	expect(t, v.Interface(), 42)
+
+	v, level, ok = handler.GetNearest(reflect.TypeOf(&Greeter{}))
+	expect(t, ok, true)
+	expect(t, level, 1)
+	expect(t, v.Interface().(*Greeter).Name, "request")
+
+	v, level, ok = handler.GetNearest(reflect.TypeOf(""))
+	expect(t, ok, true)
+	expect(t, level, 2)
+	expect(t, v.Interface(), "app")
+
+	_, level, ok = handler.GetNearest(reflect.TypeOf(3.14))
+	expect(t, ok, false)
+	expect(t, level, 0)
+
+	// interfaces resolve at the level of the nearest implementor
+	_, level, ok = handler.GetNearest(reflect.TypeOf((*fmt.Stringer)(nil)).Elem())
+	expect(t, ok, true)
+	expect(t, level, 1)
+
+	// collections are built by the injector asked, like with Get
+	v, level, ok = handler.GetNearest(reflect.TypeOf([]fmt.Stringer{}))
+	expect(t, ok, true)
+	expect(t, level, 0)
+	expect(t, v.Len(), handler.Get(reflect.TypeOf([]fmt.Stringer{})).Len())
+}
+
+func newGreeter() *Greeter { return &Greeter{"Jeremy"} }
+
+func Test_InjectorWithFuncGuard(t *testing.T) {
+	// off by default
+	inject.New().Map(newGreeter)
+
+	injector := inject.New(inject.WithFuncGuard(nil))
+	msg := panicMessage(func() { injector.Map(newGreeter) })
+	expect(t, msg, "inject: Map given the func func() *inject_test.Greeter, use MapProvider to map the value it returns")
+	msg = panicMessage(func() { injector.MapShared(newGreeter) })
+	refute(t, msg, "")
+	injector.Set(reflect.TypeOf(newGreeter), reflect.ValueOf(newGreeter))
+	injector.Map(newGreeter())
+
+	var warnings []string
+	injector = inject.New(inject.WithFuncGuard(func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}))
+	injector.Map(newGreeter)
+	expect(t, len(warnings), 1)
+	expect(t, warnings[0], "inject: Map given the func func() *inject_test.Greeter, use MapProvider to map the value it returns")
+	expect(t, injector.Get(reflect.TypeOf(newGreeter)).IsValid(), true)
+}
+
+func Test_InjectorCanInvoke(t *testing.T) {
+	injector := inject.New()
+	injector.Map("some dependency")
+	constructed := false
+	injector.MapProvider(func(s string) *Greeter {
+		constructed = true
+		return &Greeter{s}
+	})
+
+	called := false
+	plugin := func(s string, g *Greeter) { called = true }
+	expect(t, injector.CanInvoke(plugin), nil)
+	expect(t, called, false)
+	expect(t, constructed, false)
+
+	partial := func(s string, n int, g *Greeter, f float64) {}
+	err := injector.CanInvoke(partial)
+	expect(t, err.Error(), "Cannot invoke func(string, int, *inject_test.Greeter, float64), no value for parameters 1 (int), 3 (float64)")
+
+	err = injector.CanInvoke("not a func")
+	expect(t, err.Error(), "CanInvoke expects a func, got string")
+}
+
+type Lifecyclable interface{ lifecycle() }
+
+type httpServer struct{ name string }
+type grpcServer struct{ name string }
+type jobQueue struct{ name string }
+type appConfig struct{ name string }
+type appVersion string
+
+func (*httpServer) lifecycle() {}
+func (*grpcServer) lifecycle() {}
+func (*jobQueue) lifecycle()   {}
+
+func Test_InjectorGetAllMarker(t *testing.T) {
+	injector := inject.New()
+	queue := &jobQueue{"queue"}
+	injector.Map(&httpServer{"http"})
+	injector.Map(&appConfig{"config"})
+	injector.Map(queue)
+	injector.Map(appVersion("1.0"))
+	injector.Map(&grpcServer{"grpc"})
+	// the same instance mapped again under the marker is not returned twice
+	injector.MapTo(queue, (*Lifecyclable)(nil))
+
+	marker := reflect.TypeOf((*Lifecyclable)(nil)).Elem()
+	for n := 0; n < 10; n++ {
+		var names []string
+		for _, v := range injector.GetAll(marker) {
+			switch s := v.Interface().(type) {
+			case *httpServer:
+				names = append(names, s.name)
+			case *grpcServer:
+				names = append(names, s.name)
+			case *jobQueue:
+				names = append(names, s.name)
+			}
+		}
+		expect(t, strings.Join(names, ","), "http,queue,grpc")
+	}
+}
+
+func Test_InjectorGetAllMergesInterfaceBindings(t *testing.T) {
+	parent := inject.New()
+	parent.MapTo(authMiddleware{}, (*Middleware)(nil))
+	parent.Map(gzipMiddleware{})
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.MapTo(corsMiddleware{}, (*Middleware)(nil))
+	injector.Map(gzipMiddleware{})
+
+	// Get prefers the binding of the child
+	m := injector.Get(inject.InterfaceOf((*Middleware)(nil))).Interface().(Middleware)
+	expect(t, handles([]Middleware{m}), "cors")
+
+	// GetAll keeps the binding of the parent, but one gzip only
+	vals := injector.GetAll(inject.InterfaceOf((*Middleware)(nil)))
+	ms := make([]Middleware, len(vals))
+	for i, v := range vals {
+		ms[i] = v.Interface().(Middleware)
+	}
+	expect(t, handles(ms), "cors,gzip,auth")
+}
+
+func Test_NewFromMap(t *testing.T) {
+	injector, err := inject.NewFromMap(map[reflect.Type]interface{}{
+		reflect.TypeOf(""):                        "some dependency",
+		inject.InterfaceOf((*SpecialString)(nil)): &Greeter{"Jeremy"},
+		inject.InterfaceOf((*fmt.Stringer)(nil)):  &Greeter{"Tom"},
+		reflect.TypeOf(&Config{}):                 nil,
+	})
+	expect(t, err, nil)
+
+	_, err = injector.Invoke(func(s string, g fmt.Stringer, c *Config) {
+		expect(t, s, "some dependency")
+		expect(t, g.String(), "Hello, My name isTom")
+		expect(t, c == nil, true)
+	})
+	expect(t, err, nil)
+	expect(t, injector.Get(inject.InterfaceOf((*SpecialString)(nil))).Interface().(fmt.Stringer).String(), "Hello, My name isJeremy")
+
+	_, err = inject.NewFromMap(map[reflect.Type]interface{}{
+		reflect.TypeOf(0): "not an int",
+	})
+	refute(t, err, nil)
+	expect(t, err.Error(), "Value of type string is not assignable to int")
+}
+
+func Test_InjectorConcurrentUse(t *testing.T) {
+	first, second := inject.New(), inject.New()
+	first.Map("first")
+	second.Map("second")
+	injector := inject.New()
+	injector.SetParent(first)
+	injector.On("set", func(e inject.Event) {
+		e.Src.Map(e.Data)
+	})
+	injector.Start()
+	defer injector.Stop()
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 100; n++ {
+			if n%2 == 0 {
+				injector.SetParent(second)
+			} else {
+				injector.SetParent(first)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 100; n++ {
+			injector.Fire("set", n)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 100; n++ {
+			s := injector.Get(reflect.TypeOf("")).String()
+			if s != "first" && s != "second" {
+				t.Errorf("Unexpected value %q", s)
+			}
+			injector.Apply(&struct{}{})
+		}
+	}()
+	wg.Wait()
+}
+
+type ServerSettings struct {
+	DB     *DB `inject:"t"`
+	Logger struct {
+		Out io.Writer `inject:"t"`
+	} `inject:",nested"`
+}
+
+type AppConfig struct {
+	Name   string          `inject:"t"`
+	Server *ServerSettings `inject:",nested"`
+	Other  *ServerSettings
+}
+
+func Test_InjectorApplyNested(t *testing.T) {
+	injector := inject.New()
+	injector.Map("app")
+	injector.Map(&DB{"postgres://"})
+	out := &bytes.Buffer{}
+	injector.MapTo(out, (*io.Writer)(nil))
+
+	c := AppConfig{}
+	expect(t, injector.Apply(&c), nil)
+	expect(t, c.Name, "app")
+	expect(t, c.Server.DB.DSN, "postgres://")
+	expect(t, c.Server.Logger.Out, io.Writer(out))
+	expect(t, c.Other == nil, true)
+
+	// an existing nested struct is injected in place
+	server := &ServerSettings{}
+	c = AppConfig{Server: server}
+	expect(t, injector.Apply(&c), nil)
+	expect(t, c.Server, server)
+	expect(t, server.DB.DSN, "postgres://")
+
+	errs := inject.New().ApplyAll(&AppConfig{})
+	expect(t, len(errs), 3)
+	expect(t, errs[1].Error(), "Field Server: Field DB: Value not found for type *inject_test.DB")
+	expect(t, errs[2].Error(), "Field Server: Field Logger: Field Out: Value not found for type io.Writer")
+
+	err := injector.Apply(&struct {
+		N int `inject:",nested"`
+	}{})
+	refute(t, err, nil)
+	expect(t, err.Error(), "Field N: nested value of type int is not a struct")
+}
+
+func Test_InjectorInvokeVariadic(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&Config{"sqlite://"})
+
+	out, err := injector.Invoke(func(c *Config, extras ...string) string {
+		return c.DSN + strings.Join(extras, ",")
+	})
+	expect(t, err, nil)
+	expect(t, out[0].String(), "sqlite://")
+	expect(t, injector.CanInvoke(func(c *Config, extras ...string) {}), nil)
+
+	injector.Map([]string{"a", "b"})
+	out, err = injector.Invoke(func(c *Config, extras ...string) string {
+		return c.DSN + strings.Join(extras, ",")
+	})
+	expect(t, err, nil)
+	expect(t, out[0].String(), "sqlite://a,b")
+
+	_, err = inject.New().Invoke(func(c *Config, extras ...string) {})
+	expect(t, err.Error(), "Value not found for type *inject_test.Config")
+}
+
+func Test_InjectorInvokeErr(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&Config{"sqlite://"})
+
+	boom := errors.New("boom")
+	out, err := injector.InvokeErr(func(c *Config) (string, error) {
+		return "", boom
+	})
+	expect(t, err, boom)
+	expect(t, len(out), 1)
+
+	out, err = injector.InvokeErr(func(c *Config) (string, error) {
+		return c.DSN, nil
+	})
+	expect(t, err, nil)
+	expect(t, len(out), 1)
+	expect(t, out[0].String(), "sqlite://")
+
+	out, err = injector.InvokeErr(func(c *Config) string { return c.DSN })
+	expect(t, err, nil)
+	expect(t, len(out), 1)
+
+	_, err = inject.New().InvokeErr(func(c *Config) error { return boom })
+	expect(t, err.Error(), "Value not found for type *inject_test.Config")
+}
+
+type greetHandler func(s fmt.Stringer, c *Config) string
+
+var fastCalls int
+
+func (h greetHandler) Invoke(args []interface{}) ([]reflect.Value, error) {
+	fastCalls++
+	return []reflect.Value{reflect.ValueOf(h(args[0].(fmt.Stringer), args[1].(*Config)))}, nil
+}
+
+func Test_InjectorFastInvoker(t *testing.T) {
+	injector := inject.New()
+	injector.MapTo(&Greeter{"Jeremy"}, (*fmt.Stringer)(nil))
+	injector.Map(&Config{"sqlite://"})
+
+	fastCalls = 0
+	out, err := injector.Invoke(greetHandler(func(s fmt.Stringer, c *Config) string {
+		return s.String() + " " + c.DSN
+	}))
+	expect(t, err, nil)
+	expect(t, out[0].String(), "Hello, My name isJeremy sqlite://")
+	expect(t, fastCalls, 1)
+
+	_, err = inject.New().Invoke(greetHandler(nil))
+	refute(t, err, nil)
+	expect(t, fastCalls, 1)
+}
+
+func BenchmarkInvokeReflective(b *testing.B) {
+	injector := inject.New()
+	injector.MapTo(&Greeter{"Jeremy"}, (*fmt.Stringer)(nil))
+	injector.Map(&Config{})
+	f := func(s fmt.Stringer, c *Config) string { return c.DSN }
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		injector.Invoke(f)
+	}
+}
+
+func BenchmarkInvokeFast(b *testing.B) {
+	injector := inject.New()
+	injector.MapTo(&Greeter{"Jeremy"}, (*fmt.Stringer)(nil))
+	injector.Map(&Config{})
+	f := greetHandler(func(s fmt.Stringer, c *Config) string { return c.DSN })
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		injector.Invoke(f)
+	}
+}
+
+func Test_InjectorKeys(t *testing.T) {
+	parent := inject.New()
+	parent.Map(11)
+	injector := inject.New()
+	injector.SetParent(parent)
+	expect(t, injector.Len(), 0)
+
+	injector.Map("a dep")
+	injector.MapTo(&Greeter{"Jeremy"}, (*fmt.Stringer)(nil))
+	injector.Map("another dep")
+	injector.MapProvider(func() *Config { return &Config{} })
+	expect(t, fmt.Sprint(injector.Keys()), "[string fmt.Stringer]")
+	expect(t, injector.Len(), 2)
+
+	injector.Get(reflect.TypeOf(&Config{}))
+	expect(t, fmt.Sprint(injector.Keys()), "[string fmt.Stringer *inject_test.Config]")
+	expect(t, injector.Has(reflect.TypeOf(0)), true)
+	_, level, _ := injector.GetNearest(reflect.TypeOf(0))
+	expect(t, level, 1)
+}
+
+func Test_InjectorUnmap(t *testing.T) {
+	parent := inject.New()
+	parent.Map("parent")
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.Map("child").Map(11)
+
+	strType := reflect.TypeOf("")
+	expect(t, injector.Unmap(strType), true)
+	expect(t, injector.Unmap(strType), false)
+	expect(t, injector.Get(strType).String(), "parent")
+	expect(t, fmt.Sprint(injector.Keys()), "[int]")
+
+	// a provided value is constructed anew
+	calls := 0
+	injector.MapProvider(func() *Config {
+		calls++
+		return &Config{}
+	})
+	injector.Get(reflect.TypeOf(&Config{}))
+	expect(t, inject.Unmap[*Config](injector), true)
+	injector.Get(reflect.TypeOf(&Config{}))
+	expect(t, calls, 2)
+}
+
+func Test_InjectorNoOverride(t *testing.T) {
+	injector := inject.New(inject.WithNoOverride())
+	injector.Map("first")
+	injector.MapTo(&Greeter{"Jeremy"}, (*fmt.Stringer)(nil))
+
+	expect(t, panicMessage(func() { injector.Map("second") }), "inject: type string is already mapped, use Override to replace it")
+	expect(t, panicMessage(func() { injector.MapTo(&Greeter{"Tom"}, (*fmt.Stringer)(nil)) }), "inject: type fmt.Stringer is already mapped, use Override to replace it")
+	expect(t, injector.Get(reflect.TypeOf("")).String(), "first")
+
+	injector.Override(reflect.TypeOf(""), reflect.ValueOf("second"))
+	expect(t, injector.Get(reflect.TypeOf("")).String(), "second")
+
+	// events are still dispatched
+	received := make(chan interface{}, 2)
+	injector.On("job", func(e inject.Event) { received <- e.Data })
+	injector.FireSync(context.Background(), "job", 1)
+	injector.FireSync(context.Background(), "job", 2)
+	expect(t, receive(t, received), 1)
+	expect(t, receive(t, received), 2)
+
+	// a child may still shadow its parent
+	child := inject.New(inject.WithNoOverride())
+	child.SetParent(injector)
+	child.Map("child")
+	expect(t, child.Get(reflect.TypeOf("")).String(), "child")
+}
+
+func Test_InjectorStrictImplementorsPriority(t *testing.T) {
+	injector := inject.New(inject.WithStrictImplementors())
+	injector.Map(gzipMiddleware{}).Map(authMiddleware{}).Map(logMiddleware{})
+
+	_, err := injector.Invoke(func(m Middleware) {
+		expect(t, m.Handle(), "log")
+	})
+	expect(t, err, nil)
+
+	// gzip and cors both have order 0
+	tied := inject.New(inject.WithStrictImplementors())
+	tied.Map(gzipMiddleware{}).Map(corsMiddleware{}).Map(authMiddleware{})
+	_, err = tied.Invoke(func(m Middleware) {})
+	refute(t, err, nil)
+	expect(t, strings.HasPrefix(err.Error(), "Ambiguous type inject_test.Middleware"), true)
+}
@@ -0,0 +1,207 @@
+package inject
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	cleanupType = reflect.TypeOf(func() {})
+)
+
+// ProvideCleanup invokes fn, which must have the signature
+// func(...) (T, func(), error), with its arguments injected. On success the
+// returned T is mapped under T and the returned func is registered to run on
+// Close. If fn fails its error is returned and nothing is mapped; cleanups
+// registered by earlier calls are kept and still run on Close.
+func (i *injector) ProvideCleanup(fn interface{}) error {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func || t.NumOut() != 3 || t.Out(1) != cleanupType || t.Out(2) != errorType {
+		return fmt.Errorf("ProvideCleanup expects a func returning (T, func(), error), got %v", t)
+	}
+
+	out, err := i.Invoke(fn)
+	if err != nil {
+		return err
+	}
+	if err, _ := out[2].Interface().(error); err != nil {
+		return err
+	}
+
+	i.Set(t.Out(0), out[0])
+	if cleanup, _ := out[1].Interface().(func()); cleanup != nil {
+		i.addCleanup(func() error {
+			cleanup()
+			return nil
+		})
+	}
+
+	return nil
+}
+
+// OnClose registers teardown to run on Close, for resources that are not
+// tied to a single binding such as background goroutines. Teardowns and the
+// cleanups of ProvideCleanup share one stack, so they run in reverse order
+// of registration regardless of how they were registered. Mapped values are
+// never closed implicitly, not even those implementing io.Closer; register
+// their Close method with OnClose if needed.
+func (i *injector) OnClose(teardown func() error) {
+	i.addCleanup(teardown)
+}
+
+// addCleanup registers cleanup to run on Close.
+func (i *injector) addCleanup(cleanup func() error) {
+	i.mu.Lock()
+	i.cleanups = append(i.cleanups, cleanup)
+	i.mu.Unlock()
+}
+
+// Close runs every cleanup registered with ProvideCleanup or OnClose, the
+// most recently registered first, so values are torn down before the values
+// they were built from. Every cleanup runs, at most once, even if others
+// fail; the errors returned are joined with errors.Join.
+// Close only runs the cleanups registered with this injector. Closing a
+// child, e.g. at the end of a request scope, leaves its parents, and what
+// was registered with them, untouched, even when the child resolved those
+// values; closing a parent does not close its children either. Values
+// constructed by a provider of a parent belong to that parent, whichever
+// injector requested them.
+func (i *injector) Close() error {
+	i.mu.Lock()
+	cleanups := i.cleanups
+	i.cleanups = nil
+	i.mu.Unlock()
+
+	var errs []error
+	for n := len(cleanups) - 1; n >= 0; n-- {
+		if err := cleanups[n](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Starter is implemented by mapped values needing to be started, e.g. a
+// server listening or a worker pool, see StartHooks.
+type Starter interface {
+	OnStart(ctx context.Context) error
+}
+
+// Stopper is implemented by mapped values needing to be stopped, e.g. a
+// server shutting down gracefully, see StopHooks.
+type Stopper interface {
+	OnStop(ctx context.Context) error
+}
+
+// EventHooksFailed is the key of the event dispatched by Start, StartCtx
+// and StartFor when StartHooks fails, and by Stop when StopHooks does, if
+// this injector or one of its parents handles it. Its data is the error.
+const EventHooksFailed = "inject.hooks.failed"
+
+var errHooksStarted = errors.New("inject: the start hooks have already run, call StopHooks first")
+
+// StartHooks calls OnStart on each value mapped in this injector that
+// implements Starter, with ctx, in the order the values were mapped. For
+// values constructed by providers this is dependency order, since a
+// provider maps its value after the values it needs; values mapped with Map
+// and the like come in registration order, whatever they depend on.
+// Providers whose value has not been constructed yet are not invoked, so
+// resolve what has to run before. A value mapped under several types is
+// started once. If OnStart fails, the values started so far are stopped,
+// see StopHooks, and the error is returned. StartHooks fails without
+// starting anything if the hooks are already started and not stopped since.
+// Start runs it unless they are; like Close, it leaves the parents and children of the
+// injector alone.
+func (i *injector) StartHooks(ctx context.Context) error {
+	i.mu.Lock()
+	if i.hooksOn {
+		i.mu.Unlock()
+		return errHooksStarted
+	}
+	i.hooksOn = true
+	vals := make([]reflect.Value, 0, len(i.order))
+	for _, t := range i.order {
+		vals = append(vals, i.values[t])
+	}
+	i.mu.Unlock()
+
+	for _, v := range vals {
+		if v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if !v.IsValid() || i.isStarted(v) {
+			continue
+		}
+		if s, ok := v.Interface().(Starter); ok {
+			if err := s.OnStart(ctx); err != nil {
+				err = fmt.Errorf("Starting %v failed: %w", v.Type(), err)
+				return errors.Join(err, i.StopHooks(ctx))
+			}
+		}
+		i.mu.Lock()
+		i.started = append(i.started, v)
+		i.mu.Unlock()
+	}
+	return nil
+}
+
+// isStarted reports whether v, or the value v points to, has been started
+// by StartHooks, under whichever type.
+func (i *injector) isStarted(v reflect.Value) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for _, s := range i.started {
+		if sameValue(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameValue reports whether a and b are the same value: the same pointer,
+// map, chan or func, or equal comparable values of the same type.
+func sameValue(a, b reflect.Value) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return a.Pointer() == b.Pointer()
+	}
+	return a.Comparable() && a.Equal(b)
+}
+
+// StopHooks calls OnStop on each value started by StartHooks that
+// implements Stopper, in reverse order, so a value is stopped before its
+// dependencies, with ctx, which bounds a graceful shutdown. Values that do
+// not implement Starter count as started, so StopHooks also stops values
+// that only implement Stopper. Every value is stopped, at most once, even
+// if others fail; the errors returned are joined with errors.Join. Stop
+// runs it once the event loop has stopped.
+func (i *injector) StopHooks(ctx context.Context) error {
+	i.mu.Lock()
+	started := i.started
+	i.started, i.hooksOn = nil, false
+	i.mu.Unlock()
+
+	var errs []error
+	for n := len(started) - 1; n >= 0; n-- {
+		if s, ok := started[n].Interface().(Stopper); ok {
+			if err := s.OnStop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("Stopping %v failed: %w", started[n].Type(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hooksFailed dispatches an EventHooksFailed event for err, if handled.
+func (i *injector) hooksFailed(err error) {
+	if err != nil && i.hasHandlers(EventHooksFailed) {
+		i.run(Event{Src: i, Type: EventHooksFailed, Data: err})
+	}
+}
@@ -0,0 +1,226 @@
+package inject_test
+
+import (
+	"context"
+	"errors"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Config struct {
+	DSN string
+}
+
+type DB struct {
+	DSN string
+}
+
+type Cache struct {
+	DB *DB
+}
+
+func Test_InjectorProvideCleanup(t *testing.T) {
+	injector := inject.New()
+	injector.Map(Config{"postgres://"})
+
+	var closed []string
+	err := injector.ProvideCleanup(func(c Config) (*DB, func(), error) {
+		return &DB{c.DSN}, func() { closed = append(closed, "db") }, nil
+	})
+	expect(t, err, nil)
+
+	err = injector.ProvideCleanup(func(db *DB) (*Cache, func(), error) {
+		return &Cache{db}, func() { closed = append(closed, "cache") }, nil
+	})
+	expect(t, err, nil)
+
+	boom := errors.New("boom")
+	err = injector.ProvideCleanup(func(db *DB) (string, func(), error) {
+		return "", nil, boom
+	})
+	expect(t, err, boom)
+	expect(t, injector.Get(reflect.TypeOf("")).IsValid(), false)
+
+	cache := injector.Get(reflect.TypeOf(&Cache{})).Interface().(*Cache)
+	expect(t, cache.DB.DSN, "postgres://")
+
+	expect(t, injector.Close(), nil)
+	expect(t, strings.Join(closed, ","), "cache,db")
+
+	expect(t, injector.Close(), nil)
+	expect(t, len(closed), 2)
+}
+
+func Test_InjectorProvideCleanupSignature(t *testing.T) {
+	injector := inject.New()
+
+	refute(t, injector.ProvideCleanup(func() *DB { return nil }), nil)
+	refute(t, injector.ProvideCleanup("not a func"), nil)
+}
+
+func Test_InjectorOnClose(t *testing.T) {
+	injector := inject.New()
+
+	var closed []string
+	errWorker := errors.New("worker did not stop")
+	errConn := errors.New("connection reset")
+
+	injector.OnClose(func() error {
+		closed = append(closed, "conn")
+		return errConn
+	})
+	err := injector.ProvideCleanup(func() (*DB, func(), error) {
+		return &DB{}, func() { closed = append(closed, "db") }, nil
+	})
+	expect(t, err, nil)
+	injector.OnClose(func() error {
+		closed = append(closed, "worker")
+		return errWorker
+	})
+
+	err = injector.Close()
+	expect(t, strings.Join(closed, ","), "worker,db,conn")
+	expect(t, errors.Is(err, errWorker), true)
+	expect(t, errors.Is(err, errConn), true)
+
+	expect(t, injector.Close(), nil)
+}
+
+func Test_InjectorCloseChildScope(t *testing.T) {
+	app := inject.New()
+	var closed []string
+	err := app.ProvideCleanup(func() (*DB, func(), error) {
+		return &DB{"postgres://"}, func() { closed = append(closed, "db") }, nil
+	})
+	expect(t, err, nil)
+	app.OnClose(func() error {
+		closed = append(closed, "app")
+		return nil
+	})
+
+	request := inject.New()
+	request.SetParent(app)
+	err = request.ProvideCleanup(func(db *DB) (*Cache, func(), error) {
+		return &Cache{db}, func() { closed = append(closed, "cache") }, nil
+	})
+	expect(t, err, nil)
+	request.OnClose(func() error {
+		closed = append(closed, "request")
+		return nil
+	})
+
+	expect(t, request.Close(), nil)
+	expect(t, strings.Join(closed, ","), "request,cache")
+
+	// the parent is still open and serves the next scope
+	_, err = app.Invoke(func(db *DB) {
+		expect(t, db.DSN, "postgres://")
+	})
+	expect(t, err, nil)
+
+	expect(t, app.Close(), nil)
+	expect(t, strings.Join(closed, ","), "request,cache,app,db")
+}
+
+// hook records its start and stop in log, failing to start if fail is set.
+type hook struct {
+	name string
+	log  *[]string
+	fail bool
+}
+
+func (h *hook) OnStart(ctx context.Context) error {
+	if h.fail {
+		return errors.New("boom")
+	}
+	*h.log = append(*h.log, "start "+h.name)
+	return nil
+}
+
+func (h *hook) OnStop(ctx context.Context) error {
+	*h.log = append(*h.log, "stop "+h.name)
+	return nil
+}
+
+type dbHook struct{ *hook }
+type serverHook struct{ *hook }
+
+// stopOnly only implements Stopper.
+type stopOnly struct{ log *[]string }
+
+func (s stopOnly) OnStop(ctx context.Context) error {
+	*s.log = append(*s.log, "stop only")
+	return nil
+}
+
+func Test_InjectorLifecycleHooks(t *testing.T) {
+	var log []string
+	injector := inject.New()
+	injector.MapProvider(func(db dbHook) serverHook { return serverHook{&hook{"server", &log, false}} })
+	injector.MapProvider(func() dbHook { return dbHook{&hook{"db", &log, false}} })
+	injector.Map(stopOnly{&log})
+	// mapped twice, started once
+	cache := &hook{"cache", &log, false}
+	injector.Map(cache)
+	injector.MapTo(cache, (*inject.Starter)(nil))
+
+	_, err := injector.Invoke(func(s serverHook) {})
+	expect(t, err, nil)
+
+	ctx := context.Background()
+	expect(t, injector.StartHooks(ctx), nil)
+	expect(t, injector.StopHooks(ctx), nil)
+	expect(t, strings.Join(log, ", "), "start cache, start db, start server, stop server, stop db, stop cache, stop only")
+
+	// nothing is stopped twice
+	expect(t, injector.StopHooks(ctx), nil)
+	expect(t, len(log), 7)
+}
+
+func Test_InjectorLifecycleHooksStartFailure(t *testing.T) {
+	var log []string
+	injector := inject.New()
+	injector.Map(&hook{"db", &log, false})
+	injector.Map(dbHook{&hook{"server", &log, true}})
+
+	err := injector.StartHooks(context.Background())
+	refute(t, err, nil)
+	expect(t, err.Error(), "Starting inject_test.dbHook failed: boom")
+	expect(t, strings.Join(log, ", "), "start db, stop db")
+}
+
+func Test_InjectorLifecycleHooksStartStop(t *testing.T) {
+	var log []string
+	injector := inject.New()
+	db := dbHook{&hook{"db", &log, false}}
+	// a struct value mapped twice is started once
+	injector.Map(db)
+	injector.MapTo(db, (*inject.Stopper)(nil))
+
+	injector.Start()
+	refute(t, injector.StartHooks(context.Background()), nil)
+	injector.Stop()
+	expect(t, strings.Join(log, ", "), "start db, stop db")
+
+	// hooks can run again once stopped
+	expect(t, injector.StartHooks(context.Background()), nil)
+	expect(t, injector.StopHooks(context.Background()), nil)
+	expect(t, len(log), 4)
+}
+
+func Test_InjectorLifecycleHooksStartFailureEvent(t *testing.T) {
+	var log []string
+	injector := inject.New()
+	injector.Map(&hook{"server", &log, true})
+	got := make(chan interface{}, 1)
+	injector.On(inject.EventHooksFailed, func(e inject.Event) {
+		got <- e.Data.(error).Error()
+	})
+
+	injector.Start()
+	expect(t, receive(t, got), "Starting *inject_test.hook failed: boom")
+	// the event loop was not started
+	injector.Stop()
+}
@@ -0,0 +1,90 @@
+package inject
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// EventBindingChanged is the key of the events fired, by injectors created
+// WithMutationEvents, whenever a binding of their Type map changes. Its data
+// is a BindingChange. Like every key starting with "inject.", it is not
+// prefixed by Namespace.
+const EventBindingChanged = "inject.binding.changed"
+
+// The operations of a BindingChange.
+const (
+	// BindingSet reports a value mapped with Map, MapTo, Set and the like,
+	// or constructed by a provider.
+	BindingSet = "set"
+	// BindingRefreshed reports a provided value discarded by Refresh.
+	BindingRefreshed = "refresh"
+	// BindingUnmapped reports a value removed by Unmap.
+	BindingUnmapped = "unmap"
+)
+
+// BindingChange is the data of an EventBindingChanged event.
+type BindingChange struct {
+	// Type is the type whose binding changed.
+	Type reflect.Type
+	// Op is the operation, BindingSet, BindingRefreshed or BindingUnmapped.
+	Op string
+}
+
+// WithMutationEvents makes the injector fire an EventBindingChanged event
+// for every change of its Type map, so caches depending on a binding can be
+// invalidated or dashboards updated. The events are dispatched like
+// FireSync, one at a time and in the order of the changes, by a goroutine
+// of their own, so a handler may resolve and map values without deadlocking
+// the change it reports; the event loop does not need to be started. Since
+// mapping values from a handler reports further changes, handlers must not
+// map unconditionally. It is opt-in since every mapping then looks for
+// handlers.
+func WithMutationEvents() Option {
+	return func(i *injector) {
+		i.mutations = true
+	}
+}
+
+// mutationQueue holds the changes waiting to be dispatched.
+type mutationQueue struct {
+	mu      sync.Mutex
+	pending []BindingChange
+	running bool
+}
+
+// mutated queues an EventBindingChanged event for typ, if i reports
+// mutations, starting the goroutine dispatching the queue unless it is
+// running.
+func (i *injector) mutated(typ reflect.Type, op string) {
+	if !i.mutations || !i.hasHandlers(EventBindingChanged) {
+		return
+	}
+
+	q := &i.changes
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, BindingChange{typ, op})
+	if !q.running {
+		q.running = true
+		go i.dispatchMutations()
+	}
+}
+
+// dispatchMutations dispatches the queued changes until there are none.
+func (i *injector) dispatchMutations() {
+	q := &i.changes
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		c := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+
+		i.FireSync(context.Background(), EventBindingChanged, c)
+	}
+}
@@ -0,0 +1,62 @@
+package inject_test
+
+import (
+	"fmt"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_InjectorWithMutationEvents(t *testing.T) {
+	injector := inject.New(inject.WithMutationEvents())
+
+	changes := make(chan interface{}, 10)
+	injector.On(inject.EventBindingChanged, func(e inject.Event) {
+		c := e.Data.(inject.BindingChange)
+		changes <- fmt.Sprintf("%s %v", c.Op, c.Type)
+	})
+
+	injector.Map("value")
+	injector.MapTo(noopLogger{}, (*Logger)(nil))
+	injector.MapProvider(func() *DB { return &DB{} })
+	injector.Get(reflect.TypeOf(&DB{}))
+	injector.Refresh(reflect.TypeOf(&DB{}))
+	expect(t, receive(t, changes), "set string")
+	expect(t, receive(t, changes), "set inject_test.Logger")
+	expect(t, receive(t, changes), "set *inject_test.DB")
+	expect(t, receive(t, changes), "refresh *inject_test.DB")
+
+	// dispatching events does not report the Event it maps
+	injector.On("ping", func(e inject.Event) {})
+	injector.Start()
+	injector.Fire("ping", nil)
+	injector.Stop()
+	select {
+	case c := <-changes:
+		t.Errorf("unexpected change %v", c)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func Test_InjectorWithMutationEventsResolving(t *testing.T) {
+	injector := inject.New(inject.WithMutationEvents())
+	injector.MapProvider(func() *DB { return &DB{"postgres://"} })
+
+	// a handler may resolve the value whose construction it reports
+	dsns := make(chan interface{}, 1)
+	injector.On(inject.EventBindingChanged, func(e inject.Event, db *DB) {
+		dsns <- db.DSN
+	})
+	injector.Get(reflect.TypeOf(&DB{}))
+	expect(t, receive(t, dsns), "postgres://")
+}
+
+func Test_InjectorMutationEventsOff(t *testing.T) {
+	injector := inject.New()
+	injector.On(inject.EventBindingChanged, func(e inject.Event) {
+		t.Error("mutation reported without WithMutationEvents")
+	})
+	injector.Map("value")
+	time.Sleep(20 * time.Millisecond)
+}
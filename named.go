@@ -0,0 +1,136 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MapToName maps val to the interface ifacePtr points to, like MapTo, but
+// under name. Named bindings live apart from the Type map: a field tagged
+// with the name, e.g. `inject:"file"`, receives the binding, while fields
+// resolved by type never see it.
+func (i *injector) MapToName(name string, val interface{}, ifacePtr interface{}) TypeMapper {
+	t := InterfaceOf(ifacePtr)
+
+	i.mu.Lock()
+	if i.named[name] == nil {
+		i.named[name] = make(map[reflect.Type]reflect.Value)
+	}
+	i.named[name][t] = reflect.ValueOf(val)
+	i.mu.Unlock()
+	return i
+}
+
+// MapNamed maps val under name as its concrete type, like Map, without
+// touching the Type map, so that two values of one type do not overwrite
+// each other, e.g. MapNamed("primary", db1) and MapNamed("replica", db2).
+// They are resolved by GetNamed or by fields tagged with the name.
+func (i *injector) MapNamed(name string, val interface{}) TypeMapper {
+	v := reflect.ValueOf(val)
+
+	i.mu.Lock()
+	if i.named[name] == nil {
+		i.named[name] = make(map[reflect.Type]reflect.Value)
+	}
+	i.named[name][v.Type()] = v
+	i.mu.Unlock()
+	return i
+}
+
+// GetNamed returns the binding registered under name for t by i or the
+// nearest of its parents, or a zeroed Value if there is none or it is
+// ambiguous.
+func (i *injector) GetNamed(name string, t reflect.Type) reflect.Value {
+	v, _, _ := i.getNamed(name, t)
+	return v
+}
+
+// getNamed returns the binding registered under name for t by i or the
+// nearest of its parents. A binding mapped to t itself is preferred over one
+// that merely implements t, and the bindings of a child that are not usable
+// as t do not hide those of its parents. ok is false if nothing is
+// registered under name at all, so the caller can fall back to resolving by
+// type, and err is set if bindings exist under name but none is usable as t.
+func (i *injector) getNamed(name string, t reflect.Type) (val reflect.Value, ok bool, err error) {
+	if name == "" {
+		return reflect.Value{}, false, nil
+	}
+
+	for inj, isInjector := i, true; isInjector; inj, isInjector = inj.parent().(*injector) {
+		if err := inj.constructNamed(name); err != nil {
+			return reflect.Value{}, true, err
+		}
+		inj.mu.RLock()
+		bindings := inj.named[name]
+		val, exact := bindings[t]
+		var candidates []reflect.Value
+		if !exact {
+			for _, v := range bindings {
+				if v.IsValid() && v.Type().AssignableTo(t) {
+					candidates = append(candidates, v)
+				}
+			}
+		}
+		inj.mu.RUnlock()
+
+		switch {
+		case exact:
+			return val, true, nil
+		case len(candidates) == 1:
+			return candidates[0], true, nil
+		case len(candidates) > 1:
+			return reflect.Value{}, true, fmt.Errorf("Ambiguous binding %q for type %v", name, t)
+		case bindings != nil:
+			ok = true
+		}
+	}
+
+	if ok {
+		err = fmt.Errorf("Binding %q does not implement %v", name, t)
+	}
+	return reflect.Value{}, ok, err
+}
+
+// hasNamed reports, like getNamed, whether a binding registered under name
+// is usable as t, and err why not, without invoking the result providers
+// having fields registered under name: those fields count by their type.
+func (i *injector) hasNamed(name string, t reflect.Type) (ok bool, err error) {
+	if name == "" {
+		return false, nil
+	}
+
+	for inj, isInjector := i, true; isInjector; inj, isInjector = inj.parent().(*injector) {
+		inj.mu.RLock()
+		types := make(map[reflect.Type]bool)
+		for typ, v := range inj.named[name] {
+			types[typ] = v.IsValid()
+		}
+		for _, nr := range inj.results[name] {
+			types[nr.typ] = true
+		}
+		inj.mu.RUnlock()
+
+		if _, exact := types[t]; exact {
+			return true, nil
+		}
+		candidates := 0
+		for typ, valid := range types {
+			if valid && typ.AssignableTo(t) {
+				candidates++
+			}
+		}
+		switch {
+		case candidates == 1:
+			return true, nil
+		case candidates > 1:
+			return true, fmt.Errorf("Ambiguous binding %q for type %v", name, t)
+		case len(types) > 0:
+			ok = true
+		}
+	}
+
+	if ok {
+		err = fmt.Errorf("Binding %q does not implement %v", name, t)
+	}
+	return ok, err
+}
@@ -0,0 +1,119 @@
+package inject_test
+
+import (
+	"fmt"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fileLogger struct{}
+
+func (fileLogger) Log(msg string) string { return "file: " + msg }
+
+type remoteLogger struct{}
+
+func (remoteLogger) Log(msg string) string { return "remote: " + msg }
+
+type Loggers struct {
+	File   Logger `inject:"file"`
+	Remote Logger `inject:"remote"`
+	Any    Logger `inject:"t"`
+}
+
+func Test_InjectorMapToName(t *testing.T) {
+	parent := inject.New()
+	parent.MapToName("remote", remoteLogger{}, (*Logger)(nil))
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.MapToName("file", fileLogger{}, (*Logger)(nil))
+	injector.MapTo(noopLogger{}, (*Logger)(nil))
+
+	l := Loggers{}
+	expect(t, injector.Apply(&l), nil)
+	expect(t, l.File.Log("x"), "file: x")
+	expect(t, l.Remote.Log("x"), "remote: x")
+	expect(t, l.Any, Logger(noopLogger{}))
+}
+
+type NamedGreeter struct {
+	Greeter Logger `inject:"greeter"`
+}
+
+func Test_InjectorMapToNameWrongInterface(t *testing.T) {
+	injector := inject.New()
+	injector.MapToName("greeter", &Greeter{"Jeremy"}, (*fmt.Stringer)(nil))
+
+	err := injector.Apply(&NamedGreeter{})
+	refute(t, err, nil)
+	expect(t, strings.Contains(err.Error(), `Binding "greeter" does not implement inject_test.Logger`), true)
+}
+
+func Test_InjectorMapToNameParentFallback(t *testing.T) {
+	parent := inject.New()
+	parent.MapToName("file", fileLogger{}, (*Logger)(nil))
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.MapToName("file", &Greeter{"Jeremy"}, (*fmt.Stringer)(nil))
+
+	l := Loggers{}
+	injector.MapTo(noopLogger{}, (*Logger)(nil))
+	injector.MapToName("remote", remoteLogger{}, (*Logger)(nil))
+	expect(t, injector.Apply(&l), nil)
+	expect(t, l.File.Log("x"), "file: x")
+}
+
+type Replicas struct {
+	Primary *DB    `inject:"t"`
+	Replica *DB    `inject:"t"`
+	Config  Config `inject:"t"`
+	Backup  *DB
+}
+
+func Test_InjectorApplyNamedFields(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&DB{"shared://"})
+	injector.Map(Config{"config://"})
+
+	r := Replicas{}
+	err := injector.ApplyNamed(&r, map[string]interface{}{
+		"Primary": &DB{"primary://"},
+		"Replica": &DB{"replica://"},
+		"Backup":  &DB{"backup://"},
+	})
+	expect(t, err, nil)
+	expect(t, r.Primary.DSN, "primary://")
+	expect(t, r.Replica.DSN, "replica://")
+	expect(t, r.Backup.DSN, "backup://")
+	expect(t, r.Config.DSN, "config://")
+
+	// the injector is left as it was
+	expect(t, injector.Apply(&r), nil)
+	expect(t, r.Primary.DSN, "shared://")
+
+	err = injector.ApplyNamed(&r, map[string]interface{}{"Secondary": &DB{}})
+	expect(t, err.Error(), "ApplyNamed: inject_test.Replicas has no field Secondary")
+	err = injector.ApplyNamed(&r, map[string]interface{}{"Primary": "primary://"})
+	expect(t, err.Error(), "Field Primary: value of type string is not assignable to *inject_test.DB")
+}
+
+func Test_InjectorMapNamed(t *testing.T) {
+	parent := inject.New()
+	parent.MapNamed("replica", &DB{"postgres://replica"})
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.MapNamed("primary", &DB{"postgres://primary"})
+	injector.MapToName("remote", remoteLogger{}, (*Logger)(nil))
+
+	dbType := reflect.TypeOf(&DB{})
+	expect(t, injector.GetNamed("primary", dbType).Interface().(*DB).DSN, "postgres://primary")
+	expect(t, injector.GetNamed("replica", dbType).Interface().(*DB).DSN, "postgres://replica")
+	expect(t, injector.GetNamed("remote", inject.InterfaceOf((*Logger)(nil))).Interface().(Logger).Log("x"), "remote: x")
+	expect(t, injector.GetNamed("missing", dbType).IsValid(), false)
+	// named values stay out of the Type map
+	expect(t, injector.Get(dbType).IsValid(), false)
+}
@@ -0,0 +1,88 @@
+package inject
+
+import (
+	"context"
+	"strings"
+)
+
+// namespace is an Injector whose event keys are prefixed, see Namespace.
+type namespace struct {
+	Injector
+	prefix string
+}
+
+// Namespace returns a view of the injector that shares its Type map, its
+// parent and its event loop, but prefixes every event and state key given
+// to On, OnFrom, Fire, FireCtx, FireSync, FireSyncCtx, Request,
+// DeclareEvent, SetState and OnState with prefix and a dot, so modules
+// using the same generic key, e.g. "error", do not receive each other's
+// events. Handlers see the
+// prefixed key as the Type of their Event. To fire an event of another
+// namespace, fire its full key, e.g. "billing.error", on the injector itself
+// or pass it to that namespace. Keys starting with "inject.", such as
+// EventLoopExpired, belong to the injector and are never prefixed.
+// Namespaces of a namespace nest their prefixes, and a namespace given to
+// SetParent or OnFrom stands for the injector it is a view of.
+func (i *injector) Namespace(prefix string) Injector {
+	return &namespace{Injector: i, prefix: prefix}
+}
+
+func (n *namespace) Namespace(prefix string) Injector {
+	return &namespace{Injector: n.Injector, prefix: n.key(prefix)}
+}
+
+// key returns key in the namespace of n.
+func (n *namespace) key(key string) string {
+	if strings.HasPrefix(key, "inject.") {
+		return key
+	}
+	return n.prefix + "." + key
+}
+
+// unwrap returns the injector a namespace is a view of, or src itself.
+func unwrap(src Injector) Injector {
+	if n, ok := src.(*namespace); ok {
+		return n.Injector
+	}
+	return src
+}
+
+func (n *namespace) On(key string, handlers ...Handler) {
+	n.Injector.On(n.key(key), handlers...)
+}
+
+func (n *namespace) OnFrom(src Injector, key string, handler Handler) {
+	n.Injector.OnFrom(src, n.key(key), handler)
+}
+
+func (n *namespace) Fire(key string, data interface{}) {
+	n.Injector.Fire(n.key(key), data)
+}
+
+func (n *namespace) FireCtx(ctx context.Context, key string, data interface{}) {
+	n.Injector.FireCtx(ctx, n.key(key), data)
+}
+
+func (n *namespace) FireSync(ctx context.Context, key string, data interface{}) {
+	n.Injector.FireSync(ctx, n.key(key), data)
+}
+
+func (n *namespace) FireSyncCtx(ctx context.Context, key string, data interface{}) error {
+	return n.Injector.FireSyncCtx(ctx, n.key(key), data)
+}
+
+func (n *namespace) Request(key string, data interface{}) (interface{}, error) {
+	return n.Injector.Request(n.key(key), data)
+}
+
+func (n *namespace) DeclareEvent(key string, sample interface{}) {
+	n.Injector.DeclareEvent(n.key(key), sample)
+}
+
+func (n *namespace) SetState(key string, value interface{}) {
+	n.Injector.SetState(n.key(key), value)
+}
+
+func (n *namespace) OnState(key string, handler Handler) {
+	n.Injector.OnState(n.key(key), handler)
+}
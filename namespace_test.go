@@ -0,0 +1,53 @@
+package inject_test
+
+import (
+	"github.com/codegangsta/inject"
+	"reflect"
+	"testing"
+)
+
+func Test_InjectorNamespace(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&Greeter{"Jeremy"})
+	injector.Start()
+	defer injector.Stop()
+
+	billing := injector.Namespace("billing")
+	shipping := injector.Namespace("shipping")
+
+	billingErrors := make(chan interface{}, 2)
+	billing.On("error", func(e inject.Event, g *Greeter) {
+		billingErrors <- e.Type + " " + e.Data.(string) + " for " + g.Name
+	})
+	shippingErrors := make(chan interface{}, 2)
+	shipping.On("error", func(e inject.Event) {
+		shippingErrors <- e.Type + " " + e.Data.(string)
+	})
+
+	billing.Fire("error", "declined")
+	expect(t, receive(t, billingErrors), "billing.error declined for Jeremy")
+	shipping.Fire("error", "lost")
+	expect(t, receive(t, shippingErrors), "shipping.error lost")
+
+	// cross-namespace events are fired with their full key
+	injector.Fire("billing.error", "refunded")
+	expect(t, receive(t, billingErrors), "billing.error refunded for Jeremy")
+	select {
+	case v := <-shippingErrors:
+		t.Errorf("unexpected shipping event %v", v)
+	default:
+	}
+
+	// the Type map is shared
+	shipping.Map("shared")
+	expect(t, billing.Get(reflect.TypeOf("")).Interface(), "shared")
+
+	// nested namespaces and states
+	var got []string
+	billing.Namespace("invoices").OnState("total", func(e inject.Event) {
+		got = append(got, e.Type)
+	})
+	injector.SetState("billing.invoices.total", 42)
+	expect(t, len(got), 1)
+	expect(t, got[0], "billing.invoices.total")
+}
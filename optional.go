@@ -0,0 +1,60 @@
+package inject
+
+import "reflect"
+
+// Optional wraps a dependency that may be missing. A parameter or field of
+// type Optional[T] never fails to resolve for Invoke and Apply: Present
+// reports whether T could be resolved and Value holds it, or the zero value
+// of T if it could not. A provider of T failing to construct it is still an
+// error.
+type Optional[T any] struct {
+	Value   T
+	Present bool
+}
+
+// optional is implemented by every Optional[T].
+type optional interface {
+	elemType() reflect.Type
+	wrap(v reflect.Value) reflect.Value
+}
+
+func (Optional[T]) elemType() reflect.Type {
+	return typeFor[T]()
+}
+
+// wrap returns an Optional[T] holding v, or an absent one if v is the zero
+// Value.
+func (Optional[T]) wrap(v reflect.Value) reflect.Value {
+	var o Optional[T]
+	if v.IsValid() {
+		o.Value, _ = v.Interface().(T)
+		o.Present = true
+	}
+	return reflect.ValueOf(o)
+}
+
+var optionalType = reflect.TypeOf((*optional)(nil)).Elem()
+
+// optionalOf returns t as an optional if t is an Optional[T].
+func optionalOf(t reflect.Type) (optional, bool) {
+	if t.Kind() != reflect.Struct || !t.Implements(optionalType) {
+		return nil, false
+	}
+	return reflect.Zero(t).Interface().(optional), true
+}
+
+// resolveOptional resolves the T of the Optional[T] o for a provider
+// constructing the types on path, without reporting a miss.
+func (i *injector) resolveOptional(o optional, path *construction) (reflect.Value, error) {
+	t := o.elemType()
+	val, err := i.lookup(t, path)
+	if !val.IsValid() && err == nil {
+		if supply := i.defaultSupplier(t); supply != nil {
+			val = supply()
+		}
+	}
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return o.wrap(val), nil
+}
@@ -0,0 +1,45 @@
+package inject_test
+
+import (
+	"errors"
+	"github.com/codegangsta/inject"
+	"testing"
+)
+
+type OptionalCollaborators struct {
+	DB     inject.Optional[*DB]    `inject:"t"`
+	Logger inject.Optional[Logger] `inject:"t"`
+}
+
+func Test_InjectorOptional(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&DB{"sqlite://"})
+
+	c := OptionalCollaborators{}
+	expect(t, injector.Apply(&c), nil)
+	expect(t, c.DB.Present, true)
+	expect(t, c.DB.Value.DSN, "sqlite://")
+	expect(t, c.Logger.Present, false)
+	expect(t, c.Logger.Value, nil)
+
+	_, err := injector.Invoke(func(l inject.Optional[Logger], n inject.Optional[int]) {
+		expect(t, l.Present, false)
+		expect(t, n.Present, false)
+		expect(t, n.Value, 0)
+	})
+	expect(t, err, nil)
+
+	injector.MapTo(noopLogger{}, (*Logger)(nil))
+	expect(t, injector.Apply(&c), nil)
+	expect(t, c.Logger.Present, true)
+	expect(t, c.Logger.Value, Logger(noopLogger{}))
+}
+
+func Test_InjectorOptionalProviderError(t *testing.T) {
+	injector := inject.New()
+	injector.MapProvider(func() (*DB, error) { return nil, errors.New("connection refused") })
+
+	_, err := injector.Invoke(func(db inject.Optional[*DB]) {})
+	refute(t, err, nil)
+	expect(t, err.Error(), "Provider for type *inject_test.DB failed: connection refused")
+}
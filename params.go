@@ -0,0 +1,97 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// In is embedded in a struct to make it a parameter struct: a parameter of
+// that struct type, of a func invoked by Invoke or a provider, receives a
+// struct whose exported fields are resolved like parameters of their own,
+// which keeps constructors with many dependencies readable, e.g.
+//
+//	type ServerParams struct {
+//		inject.In
+//		DB     *sql.DB
+//		Cache  Cache  `inject:"optional"`
+//		Routes []Route `inject:"group:routes"`
+//	}
+//
+//	func NewServer(p ServerParams) *Server
+//
+// Fields need no tag. An inject tag naming a binding registered with
+// MapNamed or MapToName, or a group, or holding the "optional" option, is
+// honoured like by Apply; an optional field that cannot be resolved is left
+// as its zero value.
+type In struct{}
+
+var inType = reflect.TypeOf(In{})
+
+// isParams reports whether t is a parameter struct, embedding In.
+func isParams(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for n := 0; n < t.NumField(); n++ {
+		if f := t.Field(n); f.Anonymous && f.Type == inType {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveParams returns the parameter struct t with its fields resolved for
+// a provider constructing the types on path, if any.
+func (i *injector) resolveParams(t reflect.Type, path *construction) (reflect.Value, error) {
+	s := reflect.New(t).Elem()
+	for n, plan := range planFields(t) {
+		sf := plan.field
+		if sf.Type == inType || !sf.IsExported() {
+			continue
+		}
+		var v reflect.Value
+		var err error
+		if plan.group != "" {
+			v, err = i.getGroupSlice(sf.Type, plan.group, path)
+		} else if nv, ok, nerr := i.getNamed(plan.name, sf.Type); ok || nerr != nil {
+			v, err = nv, nerr
+		} else {
+			v, err = i.resolveArgOn(sf.Type, path)
+		}
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("Field %s of %v: %w", sf.Name, t, err)
+		}
+		if !v.IsValid() {
+			if hasOption(plan.opts, "optional") {
+				continue
+			}
+			return reflect.Value{}, fmt.Errorf("Field %s of %v: Value not found for type %v", sf.Name, t, sf.Type)
+		}
+		s.Field(n).Set(v)
+	}
+	return s, nil
+}
+
+// hasParams reports whether every field of the parameter struct t that is
+// not optional can be resolved, see Has.
+func (i *injector) hasParams(t reflect.Type) bool {
+	for _, plan := range planFields(t) {
+		sf := plan.field
+		if sf.Type == inType || !sf.IsExported() {
+			continue
+		}
+		if plan.group != "" || hasOption(plan.opts, "optional") {
+			continue
+		}
+		if ok, err := i.hasNamed(plan.name, sf.Type); ok {
+			if err != nil {
+				return false
+			}
+			continue
+		}
+		if !i.Has(sf.Type) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package inject_test
+
+import (
+	"errors"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"testing"
+)
+
+type RepoParams struct {
+	inject.In
+	DB      *DB
+	Logger  Logger        `inject:"file"`
+	Cache   *Cache        `inject:"optional"`
+	Routes  []*GroupRoute `inject:"group:routes"`
+	Greeter inject.Optional[*Greeter]
+	ignored string
+}
+
+func Test_InjectorParamStruct(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&Config{"postgres://"})
+	injector.MapProvider(func(c *Config) *DB { return &DB{c.DSN} })
+	injector.MapToName("file", fileLogger{}, (*Logger)(nil))
+	injector.MapGroup("routes", &GroupRoute{"/"})
+
+	injector.MapProvider(func(p RepoParams) *UserRepo {
+		expect(t, p.Logger.Log("x"), "file: x")
+		expect(t, p.Cache == nil, true)
+		expect(t, len(p.Routes), 1)
+		expect(t, p.Greeter.Present, false)
+		return &UserRepo{p.DB}
+	})
+
+	_, err := injector.Invoke(func(r *UserRepo, p RepoParams) {
+		expect(t, r.DB.DSN, "postgres://")
+		expect(t, p.DB, r.DB)
+	})
+	expect(t, err, nil)
+	expect(t, injector.CanInvoke(func(p RepoParams) {}), nil)
+}
+
+func Test_InjectorParamStructErrors(t *testing.T) {
+	injector := inject.New()
+	injector.MapToName("file", fileLogger{}, (*Logger)(nil))
+	expect(t, injector.Has(reflect.TypeOf(RepoParams{})), false)
+
+	_, err := injector.Invoke(func(p RepoParams) {})
+	expect(t, err.Error(), "Field DB of inject_test.RepoParams: Value not found for type *inject_test.DB")
+
+	boom := errors.New("boom")
+	injector.MapProvider(func() (*DB, error) { return nil, boom })
+	_, err = injector.Invoke(func(p RepoParams) {})
+	expect(t, errors.Is(err, boom), true)
+	// the provider can only tell by being invoked
+	expect(t, injector.CanInvoke(func(p RepoParams) {}), nil)
+}
@@ -0,0 +1,36 @@
+package inject
+
+import "sync"
+
+// plugins is the global registry of Register.
+var plugins struct {
+	mu   sync.Mutex
+	vals []interface{}
+}
+
+// Register adds val to the global plugin registry, to be mapped by
+// LoadPlugins. It is meant to be called from the init func of a plugin
+// package, which the application imports for its side effects only, e.g.
+// import _ "example.com/app/plugins/s3". Register is safe for concurrent
+// use. Plugins are kept in registration order, which for init funcs follows
+// the order Go initializes packages in: dependencies first, then by import
+// path. An application relying on the order of plugins should make it
+// explicit with Ordered.
+func Register(val interface{}) {
+	plugins.mu.Lock()
+	plugins.vals = append(plugins.vals, val)
+	plugins.mu.Unlock()
+}
+
+// LoadPlugins maps every plugin added to the global registry with Register,
+// in registration order, like Map. Plugins registered after it returns are
+// not mapped until it is called again.
+func (i *injector) LoadPlugins() {
+	plugins.mu.Lock()
+	vals := append([]interface{}(nil), plugins.vals...)
+	plugins.mu.Unlock()
+
+	for _, v := range vals {
+		i.Map(v)
+	}
+}
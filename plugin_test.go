@@ -0,0 +1,38 @@
+package inject_test
+
+import (
+	"github.com/codegangsta/inject"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type StoragePlugin interface {
+	Scheme() string
+}
+
+type s3Plugin struct{}
+type gcsPlugin struct{}
+
+func (*s3Plugin) Scheme() string  { return "s3" }
+func (*gcsPlugin) Scheme() string { return "gs" }
+
+func init() {
+	inject.Register(&s3Plugin{})
+	inject.Register(&gcsPlugin{})
+}
+
+func Test_InjectorLoadPlugins(t *testing.T) {
+	injector := inject.New()
+	expect(t, len(injector.GetAll(reflect.TypeOf((*StoragePlugin)(nil)).Elem())), 0)
+
+	injector.LoadPlugins()
+	_, err := injector.Invoke(func(ps []StoragePlugin, s3 *s3Plugin) {
+		var schemes []string
+		for _, p := range ps {
+			schemes = append(schemes, p.Scheme())
+		}
+		expect(t, strings.Join(schemes, ","), "s3,gs")
+	})
+	expect(t, err, nil)
+}
@@ -0,0 +1,419 @@
+package inject
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCircuitOpen is returned, wrapped, when a provider is not invoked because
+// its circuit breaker is open.
+var ErrCircuitOpen = errors.New("provider circuit open")
+
+// ErrCircularDependency is returned, wrapped, when a provider needs, directly
+// or through other providers, the type it constructs.
+var ErrCircularDependency = errors.New("circular dependency")
+
+// ProviderInterceptor is called instead of a provider func whenever a
+// provider constructs the value of type t; construct invokes the provider,
+// or the next interceptor. An interceptor may time or trace the
+// construction, or skip it and return another value, e.g. a mock, which is
+// then mapped like a constructed one.
+type ProviderInterceptor func(t reflect.Type, construct func() (reflect.Value, error)) (reflect.Value, error)
+
+// WithProviderInterceptor makes every provider of the injector construct its
+// value through intercept. Interceptors chain in the order they are given,
+// the first one being called first.
+func WithProviderInterceptor(intercept ProviderInterceptor) Option {
+	return func(i *injector) {
+		i.provideHooks = append(i.provideHooks, intercept)
+	}
+}
+
+// ProviderOption configures a provider registered with MapProvider.
+type ProviderOption func(*provider)
+
+// provider lazily constructs the value of a single type.
+type provider struct {
+	// mu serializes constructions so a singleton is constructed at most once
+	// even when several goroutines request it at the same time.
+	mu      sync.Mutex
+	fn      reflect.Value
+	typ     reflect.Type
+	breaker *breaker
+	// owner is the id of the goroutine constructing the value while it holds
+	// mu, and building the construction path then, so that a construction
+	// requesting its own value again, e.g. through a Future or an injected
+	// Injector, fails instead of waiting for itself forever.
+	owner    atomic.Int64
+	building *construction
+	// as holds the interfaces the provider is also registered for, see As.
+	as []reflect.Type
+	// transient is set if the value is constructed anew by each resolution,
+	// see Transient.
+	transient bool
+	// group is the group the provider contributes to, see Group, and member
+	// the value it constructed for the group, which is not mapped.
+	group  string
+	member reflect.Value
+	// call, if set, constructs the value instead of calling fn through
+	// reflection, resolving the arguments of fn from the injector on path.
+	call func(i *injector, path *construction) (reflect.Value, error)
+}
+
+// MapProvider maps fn to the type of its first return value. fn must return
+// either a single value or a value and an error. It is invoked, with its
+// arguments injected, the first time the type is requested and not found in
+// the Type map; a successfully constructed value is then mapped and returned
+// by every following Get, unless the Transient option is given. A failed
+// construction is retried on the next Get.
+// Resolving a provider that needs, directly or through other providers, the
+// type it constructs fails with ErrCircularDependency. A provider returning
+// a result struct provides its fields instead, see Out.
+// It panics if fn is not a func of that shape.
+func (i *injector) MapProvider(fn interface{}, opts ...ProviderOption) TypeMapper {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func || t.NumOut() < 1 || t.NumOut() > 2 || (t.NumOut() == 2 && t.Out(1) != errorType) {
+		panic(fmt.Sprintf("inject provider must be a func returning T or (T, error), got %v", t))
+	}
+
+	if isResult(t.Out(0)) {
+		i.mapResult(reflect.ValueOf(fn), opts)
+		return i
+	}
+	i.addProvider(&provider{fn: reflect.ValueOf(fn), typ: t.Out(0)}, opts)
+	return i
+}
+
+// addProvider registers p for its type, and the interfaces it is provided
+// as, after applying opts to it. It panics if p.typ does not implement one of
+// these interfaces.
+func (i *injector) addProvider(p *provider, opts []ProviderOption) {
+	i.checkShared(p.typ)
+
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.group != "" {
+		i.addMember(p.group, &groupMember{p: p})
+		return
+	}
+	for _, iface := range p.as {
+		if !p.typ.Implements(iface) {
+			panic(fmt.Sprintf("inject provider of %v cannot be provided as %v, which it does not implement", p.typ, iface))
+		}
+		i.checkShared(iface)
+	}
+
+	i.mu.Lock()
+	if i.providers[p.typ] == nil {
+		i.provided = append(i.provided, p.typ)
+	}
+	i.providers[p.typ] = p
+	for _, iface := range p.as {
+		i.providers[iface] = p
+	}
+	i.mu.Unlock()
+}
+
+// As registers the provider for each of the interfaces ifacePtrs point to,
+// e.g. As((*io.Reader)(nil), (*io.Writer)(nil)), as well as for the type it
+// constructs. Resolving any of them constructs the value once and returns
+// that same value. Providers registered as an interface are not counted
+// twice by GetAll.
+func As(ifacePtrs ...interface{}) ProviderOption {
+	ifaces := make([]reflect.Type, len(ifacePtrs))
+	for n, ptr := range ifacePtrs {
+		ifaces[n] = InterfaceOf(ptr)
+	}
+	return func(p *provider) {
+		p.as = append(p.as, ifaces...)
+	}
+}
+
+// Transient makes the provider construct a new value each time its type is
+// resolved, e.g. for per-call values, instead of constructing it once and
+// mapping it, the singleton scope providers have by default. Transient
+// values are not mapped, so Peek does not see them and Refresh has nothing
+// to discard, and concurrent resolutions construct their own value.
+func Transient() ProviderOption {
+	return func(p *provider) {
+		p.transient = true
+	}
+}
+
+// construction is the chain of types whose providers are being invoked by
+// a resolution, innermost first.
+type construction struct {
+	typ  reflect.Type
+	next *construction
+}
+
+// has reports whether t is being constructed on c.
+func (c *construction) has(t reflect.Type) bool {
+	for ; c != nil; c = c.next {
+		if c.typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the chain from the outermost type to the innermost one.
+func (c *construction) String() string {
+	var types []string
+	for ; c != nil; c = c.next {
+		types = append([]string{c.typ.String()}, types...)
+	}
+	return strings.Join(types, " -> ")
+}
+
+// reentered returns the construction path of p continued by path, the
+// path p is requested on again by the goroutine constructing it.
+func (p *provider) reentered(path *construction) *construction {
+	var inner []reflect.Type
+	for c := path; c != nil; c = c.next {
+		inner = append(inner, c.typ)
+	}
+	full := p.building
+	for n := len(inner) - 1; n >= 0; n-- {
+		full = &construction{inner[n], full}
+	}
+	return &construction{p.typ, full}
+}
+
+// goid returns the id of the calling goroutine, parsed from its stack trace
+// since Go does not expose it otherwise.
+func goid() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	id, _ := strconv.ParseInt(string(b[:bytes.IndexByte(b, ' ')]), 10, 64)
+	return id
+}
+
+// provide invokes p and maps the value it constructs. Concurrent callers
+// wait for the construction in progress and return its value instead of
+// invoking p again. path holds the types being constructed by the resolution
+// that needs p; if p constructs one of them, or the goroutine constructing
+// the value of p requests it again on a fresh path, e.g. through a Future,
+// provide fails with ErrCircularDependency instead of waiting for itself.
+func (i *injector) provide(p *provider, path *construction) (reflect.Value, error) {
+	if path.has(p.typ) {
+		path = &construction{p.typ, path}
+		return reflect.Value{}, fmt.Errorf("%w: %v", ErrCircularDependency, path)
+	}
+
+	if !p.transient {
+		if !p.mu.TryLock() {
+			if p.owner.Load() == goid() {
+				return reflect.Value{}, fmt.Errorf("%w: %v", ErrCircularDependency, p.reentered(path))
+			}
+			p.mu.Lock()
+		}
+		defer p.mu.Unlock()
+
+		val := p.member
+		if p.group == "" {
+			i.mu.RLock()
+			val = i.values[p.typ]
+			i.mu.RUnlock()
+		}
+		if val.IsValid() {
+			return val, nil
+		}
+
+		p.owner.Store(goid())
+		p.building = &construction{p.typ, path}
+		defer func() {
+			p.owner.Store(0)
+			p.building = nil
+		}()
+	}
+
+	if p.breaker != nil {
+		if err := p.breaker.allow(); err != nil {
+			return reflect.Value{}, fmt.Errorf("Provider for type %v failed: %w", p.typ, err)
+		}
+	}
+
+	val, err := i.intercepted(p, &construction{p.typ, path})
+	if p.breaker != nil {
+		p.breaker.done(err)
+	}
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("Provider for type %v failed: %w", p.typ, err)
+	}
+
+	switch {
+	case p.transient:
+	case p.group != "":
+		p.member = val
+	default:
+		i.Set(p.typ, val)
+	}
+	return val, nil
+}
+
+// Refresh discards the value constructed by the provider of t, in this
+// injector or the nearest of its parents having one, so the next Get
+// invokes the provider again, e.g. to reload a template cache without
+// rebuilding the whole container. The provider itself stays registered, so
+// unlike mapping a new value with Map the type remains lazily constructed.
+// It does nothing if t has no provider or its value has not been
+// constructed yet. Values already injected, and the dependents constructed
+// from the discarded value, keep the old value. Providers have no cleanups
+// of their own, so nothing is torn down; register one with OnClose from the
+// provider if the value needs it. A constructor returning several values,
+// see AutoWire, is not invoked again and provides its first values anew.
+func (i *injector) Refresh(t reflect.Type) {
+	for inj, ok := i, true; ok; inj, ok = inj.parent().(*injector) {
+		inj.mu.RLock()
+		p := inj.providers[t]
+		inj.mu.RUnlock()
+		if p != nil {
+			inj.discard(p)
+			return
+		}
+	}
+}
+
+// discard unmaps the value constructed by p, waiting for a construction in
+// progress.
+func (i *injector) discard(p *provider) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i.unset(p.typ) {
+		i.mutated(p.typ, BindingRefreshed)
+	}
+}
+
+// resolverOn returns a func resolving the arguments of a provider
+// constructing the types on path, failing for missing ones.
+func (i *injector) resolverOn(path *construction) func(reflect.Type) (reflect.Value, error) {
+	return func(t reflect.Type) (reflect.Value, error) {
+		v, err := i.resolveArgOn(t, path)
+		if err == nil && !v.IsValid() {
+			err = notFound(t, path)
+		}
+		return v, err
+	}
+}
+
+// notFound returns the error for a dependency t missing while constructing
+// the types on path.
+func notFound(t reflect.Type, path *construction) error {
+	return fmt.Errorf("Value not found for type %v, needed by %v", t, path)
+}
+
+// intercepted constructs the value of p through the provider interceptors
+// of i.
+func (i *injector) intercepted(p *provider, path *construction) (reflect.Value, error) {
+	construct := func() (reflect.Value, error) {
+		return i.construct(p, path)
+	}
+	for n := len(i.provideHooks) - 1; n >= 0; n-- {
+		intercept, next := i.provideHooks[n], construct
+		construct = func() (reflect.Value, error) {
+			return intercept(p.typ, next)
+		}
+	}
+
+	val, err := construct()
+	if err == nil && (!val.IsValid() || !val.Type().AssignableTo(p.typ)) {
+		err = fmt.Errorf("interceptor returned %v, which is not assignable to %v", typeOf(val), p.typ)
+	}
+	return val, err
+}
+
+// construct invokes the provider func, resolving its arguments on path, and
+// splits off its error.
+func (i *injector) construct(p *provider, path *construction) (reflect.Value, error) {
+	if p.call != nil {
+		return p.call(i, path)
+	}
+
+	out, err := i.invoke(p.fn.Interface(), i.resolverOn(path))
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if len(out) == 2 {
+		if err, _ := out[1].Interface().(error); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return out[0], nil
+}
+
+// WithCircuitBreaker guards a provider with a circuit breaker. The circuit
+// starts closed and every Get invokes the provider as usual. After threshold
+// consecutive failures it opens: for the cooldown that follows, Get fails
+// fast with ErrCircuitOpen without invoking the provider. Once the cooldown
+// has passed the circuit is half-open and lets a single construction through;
+// if it succeeds the circuit closes again, if it fails the circuit reopens
+// for another cooldown.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) ProviderOption {
+	return func(p *provider) {
+		p.breaker = &breaker{threshold: threshold, cooldown: cooldown}
+	}
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+}
+
+// allow reports whether a construction may be attempted.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = breakerHalfOpen
+	case breakerHalfOpen:
+		// a trial construction is already running
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// done records the outcome of a construction allowed by allow.
+func (b *breaker) done(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,328 @@
+package inject_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/codegangsta/inject"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type UserRepo struct {
+	DB *DB
+}
+
+func Test_InjectorMapProvider(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&DB{"postgres://"})
+
+	calls := 0
+	injector.MapProvider(func(db *DB) *UserRepo {
+		calls++
+		return &UserRepo{db}
+	})
+	expect(t, calls, 0)
+
+	_, err := injector.Invoke(func(r *UserRepo) {
+		expect(t, r.DB.DSN, "postgres://")
+	})
+	expect(t, err, nil)
+
+	first := injector.Get(reflect.TypeOf(&UserRepo{}))
+	expect(t, first.IsValid(), true)
+	expect(t, calls, 1)
+}
+
+func Test_InjectorMapProviderError(t *testing.T) {
+	injector := inject.New()
+	boom := errors.New("boom")
+	injector.MapProvider(func() (*DB, error) {
+		return nil, boom
+	})
+
+	_, err := injector.Invoke(func(db *DB) {})
+	expect(t, errors.Is(err, boom), true)
+	expect(t, injector.Get(reflect.TypeOf(&DB{})).IsValid(), false)
+}
+
+func Test_InjectorCircuitBreaker(t *testing.T) {
+	injector := inject.New()
+
+	calls := 0
+	healthy := false
+	injector.MapProvider(func() (*DB, error) {
+		calls++
+		if !healthy {
+			return nil, errors.New("db down")
+		}
+		return &DB{"postgres://"}, nil
+	}, inject.WithCircuitBreaker(2, 20*time.Millisecond))
+
+	invoke := func() error {
+		_, err := injector.Invoke(func(db *DB) {})
+		return err
+	}
+
+	// closed: failures go through to the provider
+	refute(t, invoke(), nil)
+	refute(t, invoke(), nil)
+	expect(t, calls, 2)
+
+	// open: fail fast without calling the provider
+	err := invoke()
+	expect(t, errors.Is(err, inject.ErrCircuitOpen), true)
+	expect(t, calls, 2)
+
+	// half-open: a failing trial reopens the circuit
+	time.Sleep(30 * time.Millisecond)
+	refute(t, invoke(), nil)
+	expect(t, calls, 3)
+	expect(t, errors.Is(invoke(), inject.ErrCircuitOpen), true)
+	expect(t, calls, 3)
+
+	// half-open: a succeeding trial closes it
+	time.Sleep(30 * time.Millisecond)
+	healthy = true
+	expect(t, invoke(), nil)
+	expect(t, calls, 4)
+}
+
+func Test_InjectorProviderConcurrentSingleton(t *testing.T) {
+	injector := inject.New()
+
+	var calls int32
+	injector.MapProvider(func() *DB {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &DB{"postgres://"}
+	})
+
+	typ := reflect.TypeOf(&DB{})
+	vals := make([]reflect.Value, 50)
+	var wg sync.WaitGroup
+	for n := range vals {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			vals[n] = injector.Get(typ)
+		}(n)
+	}
+	wg.Wait()
+
+	expect(t, atomic.LoadInt32(&calls), int32(1))
+	for _, v := range vals {
+		expect(t, v.Interface(), vals[0].Interface())
+	}
+}
+
+type Cyclic struct{ Peer *Acyclic }
+
+type Acyclic struct{ Peer *Cyclic }
+
+func Test_InjectorProviderCycle(t *testing.T) {
+	injector := inject.New()
+	injector.MapProvider(func(b *Acyclic) *Cyclic { return &Cyclic{b} })
+	injector.MapProvider(func(a *Cyclic) *Acyclic { return &Acyclic{a} })
+
+	done := make(chan error)
+	go func() {
+		_, err := injector.Invoke(func(a *Cyclic) {})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		expect(t, errors.Is(err, inject.ErrCircularDependency), true)
+		expect(t, strings.Contains(err.Error(), "*inject_test.Cyclic -> *inject_test.Acyclic -> *inject_test.Cyclic"), true)
+	case <-time.After(time.Second):
+		t.Fatal("resolving a provider cycle did not return")
+	}
+
+	// a provider needing itself
+	injector.MapProvider(func(c *Config) *Config { return c })
+	_, err := injector.Invoke(func(c *Config) {})
+	expect(t, errors.Is(err, inject.ErrCircularDependency), true)
+}
+
+func Test_InjectorProviderInterceptor(t *testing.T) {
+	var timed []string
+	took := map[reflect.Type]time.Duration{}
+	timing := func(typ reflect.Type, construct func() (reflect.Value, error)) (reflect.Value, error) {
+		start := time.Now()
+		v, err := construct()
+		timed = append(timed, typ.String())
+		took[typ] = time.Since(start)
+		return v, err
+	}
+	mocking := func(typ reflect.Type, construct func() (reflect.Value, error)) (reflect.Value, error) {
+		if typ == reflect.TypeOf(&DB{}) {
+			return reflect.ValueOf(&DB{"mock://"}), nil
+		}
+		return construct()
+	}
+	injector := inject.New(inject.WithProviderInterceptor(timing), inject.WithProviderInterceptor(mocking))
+
+	connected := false
+	injector.MapProvider(func() *DB {
+		connected = true
+		return &DB{"postgres://"}
+	})
+	injector.MapProvider(func(db *DB) *UserRepo {
+		time.Sleep(time.Millisecond)
+		return &UserRepo{db}
+	})
+
+	_, err := injector.Invoke(func(r *UserRepo) {
+		expect(t, r.DB.DSN, "mock://")
+	})
+	expect(t, err, nil)
+	expect(t, connected, false)
+	expect(t, strings.Join(timed, ","), "*inject_test.DB,*inject_test.UserRepo")
+	expect(t, took[reflect.TypeOf(&UserRepo{})] >= time.Millisecond, true)
+
+	invalid := inject.New(inject.WithProviderInterceptor(func(typ reflect.Type, construct func() (reflect.Value, error)) (reflect.Value, error) {
+		return reflect.ValueOf("not a DB"), nil
+	}))
+	invalid.MapProvider(func() *DB { return &DB{} })
+	_, err = invalid.Invoke(func(db *DB) {})
+	expect(t, err.Error(), "Provider for type *inject_test.DB failed: interceptor returned string, which is not assignable to *inject_test.DB")
+}
+
+type rwBuffer struct {
+	data []byte
+}
+
+func (b *rwBuffer) Read(p []byte) (int, error)  { return copy(p, b.data), nil }
+func (b *rwBuffer) Write(p []byte) (int, error) { b.data = append(b.data, p...); return len(p), nil }
+func (b *rwBuffer) String() string              { return string(b.data) }
+
+func Test_InjectorMapProviderAs(t *testing.T) {
+	injector := inject.New()
+
+	calls := 0
+	injector.MapProvider(func() *rwBuffer {
+		calls++
+		return &rwBuffer{}
+	}, inject.As((*io.Reader)(nil), (*io.Writer)(nil), (*fmt.Stringer)(nil)))
+
+	_, err := injector.Invoke(func(r io.Reader, w io.Writer, s fmt.Stringer, b *rwBuffer) {
+		expect(t, r.(*rwBuffer), b)
+		expect(t, w.(*rwBuffer), b)
+		expect(t, s.(*rwBuffer), b)
+	})
+	expect(t, err, nil)
+	expect(t, calls, 1)
+	expect(t, len(injector.GetAll(reflect.TypeOf((*io.Writer)(nil)).Elem())), 1)
+
+	msg := panicMessage(func() {
+		injector.MapProvider(func() *DB { return &DB{} }, inject.As((*io.Reader)(nil)))
+	})
+	expect(t, msg, "inject provider of *inject_test.DB cannot be provided as io.Reader, which it does not implement")
+}
+
+type TemplateCache struct {
+	Version int
+}
+
+func Test_InjectorRefresh(t *testing.T) {
+	parent := inject.New()
+	version := 0
+	parent.MapProvider(func() *TemplateCache {
+		version++
+		return &TemplateCache{version}
+	})
+	injector := inject.New()
+	injector.SetParent(parent)
+
+	typ := reflect.TypeOf(&TemplateCache{})
+	// nothing constructed yet
+	injector.Refresh(typ)
+	expect(t, version, 0)
+
+	first := injector.Get(typ).Interface().(*TemplateCache)
+	expect(t, first.Version, 1)
+	expect(t, injector.Get(typ).Interface(), first)
+
+	injector.Refresh(typ)
+	expect(t, version, 1)
+	second := injector.Get(typ).Interface().(*TemplateCache)
+	expect(t, second.Version, 2)
+	expect(t, parent.Get(typ).Interface(), second)
+
+	// mapped values are kept
+	injector.Map("kept")
+	injector.Refresh(reflect.TypeOf(""))
+	expect(t, injector.Get(reflect.TypeOf("")).Interface(), "kept")
+}
+
+type Request struct {
+	ID int
+}
+
+func Test_InjectorTransientProvider(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&DB{"postgres://"})
+
+	var calls int
+	injector.MapProvider(func() *Request {
+		calls++
+		return &Request{calls}
+	}, inject.Transient())
+	singletons := 0
+	injector.MapProvider(func(db *DB) *UserRepo {
+		singletons++
+		return &UserRepo{db}
+	})
+
+	for n := 1; n <= 3; n++ {
+		_, err := injector.Invoke(func(r *Request, repo *UserRepo) {
+			expect(t, r.ID, n)
+		})
+		expect(t, err, nil)
+	}
+	expect(t, calls, 3)
+	expect(t, singletons, 1)
+
+	_, ok := injector.Peek(reflect.TypeOf(&Request{}))
+	expect(t, ok, false)
+}
+
+func Test_InjectorParentCycle(t *testing.T) {
+	root := inject.New()
+	child := inject.New()
+	child.SetParent(root)
+	grandchild := inject.New()
+	grandchild.SetParent(child)
+
+	for _, inj := range []inject.Injector{root, child} {
+		var err error
+		func() {
+			defer func() { err, _ = recover().(error) }()
+			inj.SetParent(grandchild)
+		}()
+		expect(t, errors.Is(err, inject.ErrCircularDependency), true)
+	}
+	expect(t, panicMessage(func() { root.SetParent(root) }), "inject: the injector cannot be its own ancestor: circular dependency")
+
+	// the chain is left as it was
+	root.Map("root")
+	expect(t, grandchild.Get(reflect.TypeOf("")).String(), "root")
+}
+
+func Test_InjectorProviderReentry(t *testing.T) {
+	injector := inject.New()
+	injector.MapProvider(func() (*Greeter, error) {
+		// requested again from its own provider, on a fresh path
+		_, err := inject.Get[*Greeter](injector)
+		return nil, err
+	})
+
+	_, err := inject.Get[*Greeter](injector)
+	expect(t, errors.Is(err, inject.ErrCircularDependency), true)
+	expect(t, strings.Contains(err.Error(), "circular dependency: *inject_test.Greeter -> *inject_test.Greeter"), true)
+}
@@ -0,0 +1,153 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Out is embedded in a struct to make it a result struct: a provider
+// returning a result struct, registered with MapProvider or AutoWire,
+// provides each of its exported fields instead of the struct itself, e.g.
+//
+//	type StoreResult struct {
+//		inject.Out
+//		Users  *UserStore
+//		Orders *OrderStore
+//		Cache  Cache `inject:"cache"`
+//		Route  Route `inject:"group:routes"`
+//	}
+//
+//	func NewStores(db *sql.DB) (StoreResult, error)
+//
+// A field is provided as its type unless its inject tag names a group, which
+// the field then joins, see Group, or a binding, which the field is then
+// registered under like with MapNamed, but as the type of the field. The
+// provider is invoked once, the first time one of its fields is resolved,
+// and every field is mapped then. Provider options apply to the provider of
+// each field. Together with parameter structs, see In, this lets modules
+// declare their inputs and outputs as structs.
+type Out struct{}
+
+var outType = reflect.TypeOf(Out{})
+
+// isResult reports whether t is a result struct, embedding Out.
+func isResult(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for n := 0; n < t.NumField(); n++ {
+		if f := t.Field(n); f.Anonymous && f.Type == outType {
+			return true
+		}
+	}
+	return false
+}
+
+// resultConstructor is a provider returning a result struct. It is invoked
+// at most once successfully, whichever of its fields is requested first, and
+// maps all of them.
+type resultConstructor struct {
+	mu   sync.Mutex
+	fn   reflect.Value
+	out  reflect.Value
+	done bool
+}
+
+// namedResult is a field of a result struct registered under a name, typ
+// being the type of the field, recorded when the provider is registered so
+// that existence checks need not invoke it.
+type namedResult struct {
+	r   *resultConstructor
+	typ reflect.Type
+}
+
+// mapResult registers the provider fn returning a result struct as the
+// provider of each of its fields.
+func (i *injector) mapResult(fn reflect.Value, opts []ProviderOption) {
+	t := fn.Type().Out(0)
+	r := &resultConstructor{fn: fn}
+	for n, plan := range planFields(t) {
+		sf := plan.field
+		if sf.Type == outType || !sf.IsExported() {
+			continue
+		}
+		n := n
+		p := &provider{fn: fn, typ: sf.Type, call: func(i *injector, path *construction) (reflect.Value, error) {
+			s, err := r.construct(i, path)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return s.Field(n), nil
+		}}
+
+		name, _ := parseTag(sf.Tag.Get("inject"))
+		if group, ok := groupName(name); ok {
+			p.group = group
+			i.addMember(group, &groupMember{p: p})
+		} else if name != "" {
+			i.mu.Lock()
+			i.results[name] = append(i.results[name], namedResult{r, sf.Type})
+			i.mu.Unlock()
+		} else {
+			i.addProvider(p, opts)
+		}
+	}
+}
+
+// construct invokes the provider once and maps its fields but the one
+// requested, which its provider maps, and the group members.
+func (r *resultConstructor) construct(i *injector, path *construction) (reflect.Value, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		return r.out, nil
+	}
+
+	out, err := i.invoke(r.fn.Interface(), i.resolverOn(path))
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if len(out) == 2 {
+		if err, _ := out[1].Interface().(error); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+
+	s := out[0]
+	for n, plan := range planFields(s.Type()) {
+		sf := plan.field
+		if sf.Type == outType || !sf.IsExported() {
+			continue
+		}
+		name, _ := parseTag(sf.Tag.Get("inject"))
+		switch _, isGroup := groupName(name); {
+		case isGroup:
+		case name != "":
+			i.mu.Lock()
+			if i.named[name] == nil {
+				i.named[name] = make(map[reflect.Type]reflect.Value)
+			}
+			i.named[name][sf.Type] = s.Field(n)
+			i.mu.Unlock()
+		case path == nil || sf.Type != path.typ:
+			i.Set(sf.Type, s.Field(n))
+		}
+	}
+	r.out, r.done = s, true
+	return s, nil
+}
+
+// constructNamed invokes the result providers of i having fields registered
+// under name, so that their named bindings are mapped.
+func (i *injector) constructNamed(name string) error {
+	i.mu.RLock()
+	pending := i.results[name]
+	i.mu.RUnlock()
+	for _, nr := range pending {
+		if _, err := nr.r.construct(i, nil); err != nil {
+			return fmt.Errorf("Provider for binding %q failed: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package inject_test
+
+import (
+	"errors"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"testing"
+)
+
+type OrderStore struct {
+	DB *DB
+}
+
+type StoreResult struct {
+	inject.Out
+	Users  *UserRepo
+	Orders *OrderStore
+	Audit  Logger      `inject:"audit"`
+	Route  *GroupRoute `inject:"group:routes"`
+}
+
+func Test_InjectorResultStruct(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&DB{"postgres://"})
+	calls := 0
+	injector.MapProvider(func(db *DB) (StoreResult, error) {
+		calls++
+		return StoreResult{
+			Users:  &UserRepo{db},
+			Orders: &OrderStore{db},
+			Audit:  fileLogger{},
+			Route:  &GroupRoute{"/orders"},
+		}, nil
+	})
+	injector.MapGroup("routes", &GroupRoute{"/"})
+	expect(t, calls, 0)
+
+	_, err := injector.Invoke(func(u *UserRepo, o *OrderStore) {
+		expect(t, u.DB, o.DB)
+	})
+	expect(t, err, nil)
+	expect(t, injector.GetNamed("audit", inject.InterfaceOf((*Logger)(nil))).Interface(), Logger(fileLogger{}))
+	routes, err := inject.GroupOf[*GroupRoute](injector, "routes")
+	expect(t, err, nil)
+	expect(t, len(routes), 2)
+	expect(t, routes[0].Path, "/orders")
+	expect(t, calls, 1)
+
+	// the struct itself is not provided
+	expect(t, injector.Get(reflect.TypeOf(StoreResult{})).IsValid(), false)
+}
+
+func Test_InjectorResultStructNamedFirst(t *testing.T) {
+	injector := inject.New()
+	boom := errors.New("boom")
+	injector.MapProvider(func() (StoreResult, error) { return StoreResult{}, boom })
+
+	l := struct {
+		Audit Logger `inject:"audit"`
+	}{}
+	err := injector.Apply(&l)
+	expect(t, errors.Is(err, boom), true)
+	expect(t, err.Error(), `Field Audit: Provider for binding "audit" failed: boom`)
+
+	ok := inject.New()
+	ok.MapProvider(func() StoreResult { return StoreResult{Audit: remoteLogger{}} })
+	expect(t, ok.Apply(&l), nil)
+	expect(t, l.Audit.Log("x"), "remote: x")
+	_, found := ok.Peek(reflect.TypeOf(&UserRepo{}))
+	expect(t, found, true)
+}
+
+func Test_InjectorResultStructNamedChecks(t *testing.T) {
+	injector := inject.New()
+	calls := 0
+	injector.MapProvider(func() StoreResult {
+		calls++
+		return StoreResult{Audit: remoteLogger{}}
+	})
+
+	type AuditParams struct {
+		inject.In
+		Audit Logger `inject:"audit"`
+	}
+	l := struct {
+		Audit Logger `inject:"audit"`
+	}{}
+	expect(t, injector.Has(reflect.TypeOf(AuditParams{})), true)
+	expect(t, injector.CanInvoke(func(p AuditParams) {}), nil)
+	expect(t, len(injector.Validate(&l)), 0)
+	expect(t, calls, 0)
+
+	_, err := injector.Invoke(func(p AuditParams) {
+		expect(t, p.Audit.Log("x"), "remote: x")
+	})
+	expect(t, err, nil)
+	expect(t, calls, 1)
+}
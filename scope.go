@@ -0,0 +1,105 @@
+package inject
+
+import (
+	"context"
+	"reflect"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+type scopeKey struct{}
+
+// scope is a frame of temporary bindings carried by a context, on top of
+// the frames pushed before it. Frames are never modified once pushed.
+type scope struct {
+	parent *scope
+	values []reflect.Value
+}
+
+// get returns the value of the most recently pushed frame that is of type t
+// or, if t is an interface, implements it.
+func (s *scope) get(t reflect.Type) reflect.Value {
+	for ; s != nil; s = s.parent {
+		for _, v := range s.values {
+			if v.Type() == t || (t.Kind() == reflect.Interface && v.Type().Implements(t)) {
+				return v
+			}
+		}
+	}
+	return reflect.Value{}
+}
+
+// PushScope returns a context derived from ctx carrying values as a new
+// scope frame on top of the frames ctx already carries. InvokeCtx resolves
+// arguments from the values of the frames before consulting the Type map,
+// most recent frame first, so nested invocations see them. Go has no
+// goroutine locals, so the returned context has to be passed explicitly to
+// every nested InvokeCtx. ctx itself is left unchanged: invocations given ctx
+// keep seeing only its own frames, and contexts can be shared between
+// goroutines like any other context.
+func PushScope(ctx context.Context, values ...interface{}) context.Context {
+	frame := &scope{values: make([]reflect.Value, len(values))}
+	frame.parent, _ = ctx.Value(scopeKey{}).(*scope)
+	for n, v := range values {
+		frame.values[n] = reflect.ValueOf(v)
+	}
+	return context.WithValue(ctx, scopeKey{}, frame)
+}
+
+// InvokeCtx attempts to call f like Invoke. Arguments of type
+// context.Context receive ctx, values pushed onto ctx with PushScope are
+// consulted next, most recent first, and the Type map last.
+func (inj *injector) InvokeCtx(ctx context.Context, f interface{}) ([]reflect.Value, error) {
+	return inj.invoke(f, func(t reflect.Type) (reflect.Value, error) {
+		return inj.getCtx(ctx, t)
+	})
+}
+
+// GetCtx resolves t like Get, but like an argument of InvokeCtx: from ctx
+// itself for context.Context, then from the values pushed onto ctx with
+// PushScope and the resolvers registered with MapResolver.
+func (inj *injector) GetCtx(ctx context.Context, t reflect.Type) reflect.Value {
+	v, _ := inj.getCtx(ctx, t)
+	return v
+}
+
+// MapResolver registers resolve to compute the value of t from the context
+// of each context-aware resolution, i.e. InvokeCtx and GetCtx, for values
+// varying per request like the current tenant, without a child injector per
+// request. Resolvers are consulted after the values pushed with PushScope
+// and before the Type map; a resolver returning the zero Value leaves the
+// resolution to the Type map. Resolvers of the parents apply too, the
+// nearest first. Invoke, Apply and Get do not have a context and never
+// consult resolvers.
+func (inj *injector) MapResolver(t reflect.Type, resolve func(ctx context.Context) reflect.Value) TypeMapper {
+	inj.mu.Lock()
+	inj.resolvers[t] = resolve
+	inj.mu.Unlock()
+	return inj
+}
+
+// getCtx resolves t from ctx, its scope stack and the resolvers before
+// falling back to the Type map.
+func (inj *injector) getCtx(ctx context.Context, t reflect.Type) (reflect.Value, error) {
+	if t == contextType {
+		return reflect.ValueOf(&ctx).Elem(), nil
+	}
+	if s, ok := ctx.Value(scopeKey{}).(*scope); ok {
+		if v := s.get(t); v.IsValid() {
+			return v, nil
+		}
+	}
+	for i, ok := inj, true; ok; i, ok = i.parent().(*injector) {
+		i.mu.RLock()
+		resolve := i.resolvers[t]
+		i.mu.RUnlock()
+		if resolve == nil {
+			continue
+		}
+		if v := resolve(ctx); v.IsValid() {
+			return v, nil
+		}
+		break
+	}
+	return inj.resolveArg(t)
+}
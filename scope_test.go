@@ -0,0 +1,120 @@
+package inject_test
+
+import (
+	"context"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"testing"
+)
+
+func Test_InjectorInvokeCtxScope(t *testing.T) {
+	injector := inject.New()
+	injector.Map("outer")
+
+	var seen []string
+	_, err := injector.InvokeCtx(context.Background(), func(outer context.Context, s string) {
+		seen = append(seen, s)
+
+		inner := inject.PushScope(outer, "inner")
+		_, err := injector.InvokeCtx(inner, func(inner context.Context, s string) {
+			seen = append(seen, s)
+
+			innermost := inject.PushScope(inner, "innermost")
+			injector.InvokeCtx(innermost, func(s string) {
+				seen = append(seen, s)
+			})
+
+			injector.InvokeCtx(inner, func(s string) {
+				seen = append(seen, s)
+			})
+		})
+		expect(t, err, nil)
+
+		injector.InvokeCtx(outer, func(s string) {
+			seen = append(seen, s)
+		})
+	})
+	expect(t, err, nil)
+
+	expect(t, len(seen), 5)
+	expect(t, seen[0], "outer")
+	expect(t, seen[1], "inner")
+	expect(t, seen[2], "innermost")
+	expect(t, seen[3], "inner")
+	expect(t, seen[4], "outer")
+}
+
+func Test_InjectorPushScopeImmutable(t *testing.T) {
+	injector := inject.New()
+
+	outer := inject.PushScope(context.Background(), "outer")
+	inner := inject.PushScope(outer, "inner")
+	// a sibling frame pushed on outer does not see, or hide, inner
+	sibling := inject.PushScope(outer, 42)
+
+	get := func(ctx context.Context) (s string) {
+		injector.InvokeCtx(ctx, func(v string) { s = v })
+		return s
+	}
+	expect(t, get(outer), "outer")
+	expect(t, get(inner), "inner")
+	expect(t, get(sibling), "outer")
+
+	done := make(chan string)
+	for i := 0; i < 4; i++ {
+		go func() {
+			done <- get(inject.PushScope(outer, "goroutine"))
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		expect(t, <-done, "goroutine")
+	}
+	expect(t, get(outer), "outer")
+}
+
+func Test_InjectorInvokeCtxContext(t *testing.T) {
+	injector := inject.New()
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	_, err := injector.InvokeCtx(ctx, func(c context.Context) {
+		expect(t, c.Value(key{}), "value")
+	})
+	expect(t, err, nil)
+}
+
+type Tenant string
+
+type tenantKey struct{}
+
+func Test_InjectorMapResolver(t *testing.T) {
+	parent := inject.New()
+	parent.MapResolver(reflect.TypeOf(Tenant("")), func(ctx context.Context) reflect.Value {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			return reflect.ValueOf(Tenant(tenant))
+		}
+		return reflect.Value{}
+	})
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.Map(Tenant("default"))
+
+	acme := context.WithValue(context.Background(), tenantKey{}, "acme")
+	globex := context.WithValue(context.Background(), tenantKey{}, "globex")
+
+	var seen []Tenant
+	for _, ctx := range []context.Context{acme, globex, context.Background()} {
+		_, err := injector.InvokeCtx(ctx, func(tenant Tenant) {
+			seen = append(seen, tenant)
+		})
+		expect(t, err, nil)
+	}
+	expect(t, len(seen), 3)
+	expect(t, seen[0], Tenant("acme"))
+	expect(t, seen[1], Tenant("globex"))
+	expect(t, seen[2], Tenant("default"))
+
+	expect(t, injector.GetCtx(globex, reflect.TypeOf(Tenant(""))).Interface(), Tenant("globex"))
+	// resolution without a context does not consult resolvers
+	expect(t, injector.Get(reflect.TypeOf(Tenant(""))).Interface(), Tenant("default"))
+}
@@ -0,0 +1,51 @@
+package inject
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Serializable marks a mapped value as safe to snapshot with Export, e.g.
+// deterministic values derived from configuration. Snapshots are encoded
+// with encoding/gob, so the concrete types have to be registered with
+// gob.Register in every process calling Export or Import.
+type Serializable interface {
+	Serializable()
+}
+
+// Export encodes every value mapped in this injector that implements
+// Serializable, in registration order. Values not implementing Serializable
+// and values mapped in parents are skipped.
+func (i *injector) Export() ([]byte, error) {
+	var vals []interface{}
+	i.mu.RLock()
+	for _, k := range i.order {
+		if v := i.values[k]; v.IsValid() && v.CanInterface() {
+			if s, ok := v.Interface().(Serializable); ok {
+				vals = append(vals, s)
+			}
+		}
+	}
+	i.mu.RUnlock()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(vals); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Import decodes a snapshot created by Export and maps each value by its
+// concrete type, as Map does. Values that had been mapped to an interface
+// with MapTo are therefore mapped under their concrete type after Import.
+func (i *injector) Import(data []byte) error {
+	var vals []interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&vals); err != nil {
+		return err
+	}
+
+	for _, v := range vals {
+		i.Map(v)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package inject_test
+
+import (
+	"encoding/gob"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"testing"
+)
+
+type Settings struct {
+	Name string
+	Port int
+}
+
+func (Settings) Serializable() {}
+
+func init() {
+	gob.Register(Settings{})
+}
+
+func Test_InjectorExportImport(t *testing.T) {
+	injector := inject.New()
+	injector.Map(Settings{"api", 8080})
+	injector.Map("not serializable")
+
+	data, err := injector.Export()
+	expect(t, err, nil)
+
+	injector2 := inject.New()
+	expect(t, injector2.Import(data), nil)
+
+	val := injector2.Get(reflect.TypeOf(Settings{}))
+	expect(t, val.IsValid(), true)
+	expect(t, val.Interface(), Settings{"api", 8080})
+	expect(t, injector2.Get(reflect.TypeOf("")).IsValid(), false)
+}
+
+func Test_InjectorImportInvalid(t *testing.T) {
+	injector := inject.New()
+	refute(t, injector.Import([]byte("garbage")), nil)
+}
@@ -0,0 +1,51 @@
+package inject
+
+import "context"
+
+// SetState sets the current value of the state for key and delivers it to
+// the handlers registered for key with OnState, in the calling goroutine,
+// before it returns. Unlike the events of Fire, which are a stream of
+// occurrences delivered to the handlers registered at the time, a state only
+// retains its latest value, which handlers registered later receive too.
+// SetState panics if value does not match the type declared for key with
+// DeclareEvent. State handlers must not call SetState or OnState themselves.
+func (i *injector) SetState(key string, value interface{}) {
+	i.checkPayload(key, value)
+
+	i.stateMu.Lock()
+	defer i.stateMu.Unlock()
+	i.mu.Lock()
+	i.states[key] = value
+	hs := i.watchers[key]
+	i.mu.Unlock()
+
+	for _, h := range hs {
+		i.deliverState(key, value, h)
+	}
+}
+
+// OnState registers handler, which takes an Event like the handlers of On,
+// for the state of key. If the state is set, handler is invoked with its
+// current value before OnState returns; it is then invoked with every value
+// SetState sets.
+func (i *injector) OnState(key string, handler Handler) {
+	i.checkHandler(key, handler)
+
+	i.stateMu.Lock()
+	defer i.stateMu.Unlock()
+	i.mu.Lock()
+	i.watchers[key] = append(i.watchers[key], handler)
+	value, set := i.states[key]
+	i.mu.Unlock()
+
+	if set {
+		i.deliverState(key, value, handler)
+	}
+}
+
+// deliverState invokes handler with an Event carrying the value of the state
+// for key.
+func (i *injector) deliverState(key string, value interface{}, handler Handler) {
+	e := Event{Src: i, Type: key, Data: value}
+	i.InvokeCtx(PushScope(context.Background(), e), handler)
+}
@@ -0,0 +1,43 @@
+package inject_test
+
+import (
+	"github.com/codegangsta/inject"
+	"strings"
+	"testing"
+)
+
+func Test_InjectorState(t *testing.T) {
+	injector := inject.New()
+	injector.Map(&Greeter{"Jeremy"})
+
+	var early []string
+	injector.OnState("config", func(e inject.Event) {
+		early = append(early, e.Data.(string))
+	})
+	expect(t, len(early), 0)
+
+	injector.SetState("config", "v1")
+	injector.SetState("config", "v2")
+	expect(t, strings.Join(early, ","), "v1,v2")
+
+	// a late subscriber receives the current value at once
+	var late []string
+	injector.OnState("config", func(e inject.Event, g *Greeter) {
+		late = append(late, e.Data.(string)+" for "+g.Name)
+	})
+	expect(t, strings.Join(late, ","), "v2 for Jeremy")
+
+	injector.SetState("config", "v3")
+	expect(t, strings.Join(early, ","), "v1,v2,v3")
+	expect(t, strings.Join(late, ","), "v2 for Jeremy,v3 for Jeremy")
+
+	// states are not events
+	injector.On("config", func(e inject.Event) {
+		t.Error("state delivered to an event handler")
+	})
+	injector.SetState("config", "v4")
+
+	injector.DeclareEvent("config", "")
+	msg := panicMessage(func() { injector.SetState("config", 42) })
+	expect(t, strings.Contains(msg, `event "config" expects data of type string, got int`), true)
+}
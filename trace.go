@@ -0,0 +1,80 @@
+package inject
+
+import "reflect"
+
+// logFailure logs the resolution path of every type in types that cannot be
+// resolved, followed by err.
+func (i *injector) logFailure(types []reflect.Type, err error) {
+	for _, t := range types {
+		if !i.tracePath(nil, t, "", 0, map[reflect.Type]bool{}) {
+			i.tracePath(i.resolveLog, t, "", 0, map[reflect.Type]bool{})
+		}
+	}
+	i.resolveLog("inject: %v", err)
+}
+
+// tracePath walks the steps Get would take to resolve t, logging each of
+// them to logf unless it is nil, and reports whether t can be resolved. i is
+// at the given level of the hierarchy the resolution started in.
+// Providers are entered, not invoked: a provider counts as able to resolve
+// t when all of its arguments can be resolved.
+func (i *injector) tracePath(logf func(string, ...interface{}), t reflect.Type, indent string, level int, visiting map[reflect.Type]bool) bool {
+	log := func(format string, args ...interface{}) {
+		if logf != nil {
+			logf(indent+format, args...)
+		}
+	}
+
+	if visiting[t] {
+		log("inject: %v depends on itself", t)
+		return false
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for inj := i; inj != nil; level++ {
+		log("inject: resolving %v at level %d", t, level)
+
+		inj.mu.RLock()
+		_, mapped := inj.values[t]
+		p := inj.providers[t]
+		inj.mu.RUnlock()
+
+		if mapped {
+			log("inject: found %v at level %d", t, level)
+			return true
+		}
+
+		if p != nil {
+			log("inject: entering provider %v at level %d", p.fn.Type(), level)
+			for _, arg := range argTypes(p.fn.Type()) {
+				if !inj.tracePath(logf, arg, indent+"  ", level, visiting) {
+					return false
+				}
+			}
+			return true
+		}
+
+		if inj.scan(func(k reflect.Type, v reflect.Value) bool {
+			return (t.Kind() == reflect.Interface && k.Implements(t)) || (v.IsValid() && v.Type().AssignableTo(t))
+		}).IsValid() {
+			log("inject: found a value assignable to %v at level %d", t, level)
+			return true
+		}
+
+		next, ok := inj.parent().(*injector)
+		if !ok && inj.parent() != nil && inj.parent().Get(t).IsValid() {
+			log("inject: found %v in the parent of level %d", t, level)
+			return true
+		}
+		inj = next
+	}
+
+	if i.defaultSupplier(t) != nil {
+		log("inject: using the default supplier for %v", t)
+		return true
+	}
+
+	log("inject: %v not found", t)
+	return false
+}
@@ -0,0 +1,70 @@
+package inject_test
+
+import (
+	"fmt"
+	"github.com/codegangsta/inject"
+	"strings"
+	"testing"
+)
+
+func Test_InjectorResolveLogger(t *testing.T) {
+	var lines []string
+	logf := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	parent := inject.New()
+	parent.MapProvider(func(db *DB) *UserRepo { return &UserRepo{db} })
+	parent.MapProvider(func(c Config) *DB { return &DB{c.DSN} })
+
+	injector := inject.New(inject.WithResolveLogger(logf))
+	injector.SetParent(parent)
+	injector.Map("some dependency")
+
+	_, err := injector.Invoke(func(s string, r *UserRepo) {})
+	refute(t, err, nil)
+
+	expect(t, strings.Join(lines, "\n"), strings.Join([]string{
+		"inject: resolving *inject_test.UserRepo at level 0",
+		"inject: resolving *inject_test.UserRepo at level 1",
+		"inject: entering provider func(*inject_test.DB) *inject_test.UserRepo at level 1",
+		"  inject: resolving *inject_test.DB at level 1",
+		"  inject: entering provider func(inject_test.Config) *inject_test.DB at level 1",
+		"    inject: resolving inject_test.Config at level 1",
+		"    inject: inject_test.Config not found",
+		"inject: " + err.Error(),
+	}, "\n"))
+
+	lines = nil
+	s := UserService{}
+	refute(t, injector.Apply(&s), nil)
+	expect(t, lines[0], "inject: resolving inject_test.Logger at level 0")
+	expect(t, lines[len(lines)-2], "inject: inject_test.Logger not found")
+}
+
+func Test_InjectorResolveLoggerSuccess(t *testing.T) {
+	logged := false
+	injector := inject.New(inject.WithResolveLogger(func(string, ...interface{}) {
+		logged = true
+	}))
+	injector.Map("some dependency")
+
+	_, err := injector.Invoke(func(s string) {})
+	expect(t, err, nil)
+	expect(t, logged, false)
+}
+
+func Test_InjectorResolveLoggerLenient(t *testing.T) {
+	var lines []string
+	injector := inject.New(inject.WithResolveLogger(func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}))
+
+	_, err := injector.InvokeLenient(func(reserved int, db *DB) {}, []int{0})
+	refute(t, err, nil)
+	expect(t, strings.Join(lines, "\n"), strings.Join([]string{
+		"inject: resolving *inject_test.DB at level 0",
+		"inject: *inject_test.DB not found",
+		"inject: " + err.Error(),
+	}, "\n"))
+}
@@ -0,0 +1,127 @@
+package inject
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Validate checks, without invoking any provider or handler, that the
+// wiring of the injector and its parents is complete, so that mistakes show
+// up at startup instead of on first use. It returns a problem for each
+// argument of a provider, or of a handler registered with On or OnFrom,
+// that cannot be resolved by the injector it is registered with, as Has
+// reports it or through a default supplier for providers, and for each
+// tagged field of the structs given that Apply could not inject. Named
+// fields provided by a result struct, see Out, are checked against the
+// fields it declares, so its provider is not invoked either. Structs are
+// given as values or pointers and are not modified. Fields that are
+// optional, or take their value from candidate types, a config path or a
+// group, which are only known when Apply runs, are not checked; nested
+// fields are checked recursively. Validate returns nil if it finds no
+// problem.
+func (i *injector) Validate(structs ...interface{}) []error {
+	var errs []error
+	for inj, ok := i, true; ok; inj, ok = inj.parent().(*injector) {
+		errs = append(errs, inj.validateProviders()...)
+		errs = append(errs, inj.validateHandlers()...)
+	}
+	for _, s := range structs {
+		t := reflect.TypeOf(s)
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			errs = append(errs, fmt.Errorf("Validate expects a struct, got %v", reflect.TypeOf(s)))
+			continue
+		}
+		errs = append(errs, i.validateFields(t, "")...)
+	}
+	return errs
+}
+
+// validateProviders returns a problem for each argument of a provider of i
+// that i cannot resolve.
+func (i *injector) validateProviders() []error {
+	i.mu.RLock()
+	var providers []*provider
+	for _, t := range i.provided {
+		providers = append(providers, i.providers[t])
+	}
+	i.mu.RUnlock()
+
+	var errs []error
+	for _, p := range providers {
+		for _, arg := range argTypes(p.fn.Type()) {
+			if !i.Has(arg) && i.defaultSupplier(arg) == nil {
+				errs = append(errs, fmt.Errorf("Provider for type %v needs %v, which cannot be resolved", p.typ, arg))
+			}
+		}
+	}
+	return errs
+}
+
+// validateHandlers returns a problem for each argument of a handler of i
+// that i cannot resolve, in the order of the event keys.
+func (i *injector) validateHandlers() []error {
+	i.mu.RLock()
+	keys := make([]string, 0, len(i.handlers))
+	for key := range i.handlers {
+		keys = append(keys, key)
+	}
+	handlers := make(map[string][]Handler, len(keys))
+	for _, key := range keys {
+		handlers[key] = append([]Handler(nil), i.handlers[key]...)
+	}
+	i.mu.RUnlock()
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		for _, h := range handlers[key] {
+			if sh, ok := h.(sourceHandler); ok {
+				h = sh.handler
+			}
+			for _, arg := range argTypes(reflect.TypeOf(h)) {
+				if arg != eventType && arg != contextType && !i.Has(arg) {
+					errs = append(errs, fmt.Errorf("Handler for %q takes %v, which cannot be resolved", key, arg))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// validateFields returns a problem for each tagged field of the struct type
+// t that Apply could not inject, prefixing the field names with prefix.
+func (i *injector) validateFields(t reflect.Type, prefix string) []error {
+	var errs []error
+	for _, plan := range planFields(t) {
+		ft := plan.field.Type
+		name := prefix + plan.field.Name
+		switch {
+		case !plan.tagged || !plan.field.IsExported():
+		case plan.nested:
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() != reflect.Struct {
+				errs = append(errs, fmt.Errorf("Field %s: nested value of type %v is not a struct", name, plan.field.Type))
+				continue
+			}
+			errs = append(errs, i.validateFields(ft, name+".")...)
+		case hasOption(plan.opts, "optional"), plan.candidates != nil, plan.isConfig, plan.group != "", ft == injectorType:
+		default:
+			if ok, err := i.hasNamed(plan.name, ft); ok {
+				if err != nil {
+					errs = append(errs, fmt.Errorf("Field %s: %w", name, err))
+				}
+				continue
+			}
+			if !i.Has(ft) && i.defaultSupplier(ft) == nil {
+				errs = append(errs, fmt.Errorf("Field %s: Value not found for type %v", name, ft))
+			}
+		}
+	}
+	return errs
+}
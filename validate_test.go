@@ -0,0 +1,76 @@
+package inject_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/codegangsta/inject"
+	"reflect"
+	"testing"
+)
+
+type ValidatedService struct {
+	DB       *DB             `inject:"t"`
+	Logger   Logger          `inject:"t"`
+	Cache    *Cache          `inject:"optional"`
+	Injector inject.Injector `inject:"t"`
+	Settings struct {
+		Name string `inject:"t"`
+	} `inject:",nested"`
+	Plain *Config
+}
+
+func Test_InjectorValidate(t *testing.T) {
+	parent := inject.New()
+	parent.MapProvider(func(c *Config) *DB { return &DB{c.DSN} })
+
+	injector := inject.New()
+	injector.SetParent(parent)
+	injector.Map(&Greeter{"Jeremy"})
+	injector.MapProvider(func(db *DB, s fmt.Stringer) *UserRepo { return &UserRepo{db} })
+	injector.On("saved", func(e inject.Event, ctx context.Context, g *Greeter) {})
+
+	errs := injector.Validate(&ValidatedService{}, 42)
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+	expect(t, fmt.Sprint(got), fmt.Sprint([]string{
+		// *DB has a provider, which cannot construct it
+		"Provider for type *inject_test.UserRepo needs *inject_test.DB, which cannot be resolved",
+		"Provider for type *inject_test.DB needs *inject_test.Config, which cannot be resolved",
+		"Field DB: Value not found for type *inject_test.DB",
+		"Field Logger: Value not found for type inject_test.Logger",
+		"Field Settings.Name: Value not found for type string",
+		"Validate expects a struct, got int",
+	}))
+
+	// nothing was constructed
+	_, ok := parent.Peek(reflect.TypeOf(&DB{}))
+	expect(t, ok, false)
+
+	parent.Map(&Config{"sqlite://"})
+	injector.MapTo(noopLogger{}, (*Logger)(nil))
+	injector.Map("app")
+	expect(t, len(injector.Validate(ValidatedService{})), 0)
+}
+
+func Test_InjectorValidateResultStruct(t *testing.T) {
+	injector := inject.New()
+	calls := 0
+	injector.MapProvider(func() StoreResult {
+		calls++
+		return StoreResult{}
+	})
+
+	audit := struct {
+		Audit Logger `inject:"audit"`
+	}{}
+	wrong := struct {
+		Audit *DB `inject:"audit"`
+	}{}
+	expect(t, len(injector.Validate(&audit)), 0)
+	errs := injector.Validate(&wrong)
+	expect(t, len(errs), 1)
+	expect(t, errs[0].Error(), `Field Audit: Binding "audit" does not implement *inject_test.DB`)
+	expect(t, calls, 0)
+}